@@ -4,67 +4,388 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/adapters/store"
 	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/config"
+	"github.com/metriodev/pompiers/internal/logging"
+	"github.com/metriodev/pompiers/internal/metrics"
+	"github.com/metriodev/pompiers/internal/middleware"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
 	"github.com/metriodev/pompiers/internal/server"
+	"github.com/metriodev/pompiers/internal/tracing"
 )
 
 type Cli struct {
-	Run     RunCMD `cmd:"" description:"Run the server"`
-	Timeout int    `default:"5000" help:"Timeout for shutdown in seconds"`
+	Run       RunCMD     `cmd:"" description:"Run the server"`
+	Timeout   int        `default:"5000" help:"Timeout for shutdown in seconds"`
+	LogFormat string     `default:"text" enum:"text,json" help:"Format of the log records: text or json"`
+	LogLevel  slog.Level `default:"info" help:"Minimum level of the log records: debug, info, warn or error"`
 }
 
 type RunCMD struct {
-	Port               int    `default:"8080" help:"Port to run the server on"`
-	Host               string `help:"Host to run the server on"`
-	AtlassianApiKey    string `required:"" help:"Atlassian API key"`
-	AtlassianApiUser   string `required:"" help:"Atlassian API user"`
-	AtlassianCloudId   string `required:"" help:"Atlassian cloud ID"`
-	SlackSigningSecret string `required:"" help:"Slack signing secret"`
+	Config                      kong.ConfigFlag `help:"Path of a YAML configuration file, reloaded on SIGHUP"`
+	Transport                   string          `default:"http" enum:"http,socket" help:"How Slack requests are received: a public HTTP endpoint or a Socket Mode websocket"`
+	Port                        int             `default:"8080" help:"Port to run the server on"`
+	Host                        string          `help:"Host to run the server on"`
+	AtlassianApiKey             string          `required:"" help:"Atlassian API key"`
+	AtlassianApiUser            string          `required:"" help:"Atlassian API user"`
+	AtlassianCloudId            string          `required:"" help:"Atlassian cloud ID"`
+	AtlassianSiteUrl            string          `required:"" help:"Atlassian site URL, e.g. https://your-domain.atlassian.net, used for the Jira API"`
+	AtlassianApiUrl             string          `default:"https://api.atlassian.com" help:"Atlassian API gateway URL, used for the Compass API"`
+	AtlassianAttempts           int             `default:"4" help:"Maximum number of attempts of throttled or failed Atlassian requests"`
+	AtlassianBudget             time.Duration   `default:"15s" help:"Total time allowed for the attempts of an Atlassian request"`
+	AtlassianTimeout            time.Duration   `default:"10s" help:"Deadline of every call made to the Atlassian APIs"`
+	AtlassianWorkers            int             `default:"8" help:"Number of schedules fetched in parallel"`
+	AtlassianRate               float64         `default:"10" help:"Requests per second allowed to each Atlassian host, 0 to disable the limit"`
+	AtlassianBurst              int             `default:"10" help:"Requests allowed in a burst to each Atlassian host"`
+	ScheduleUrl                 string          `default:"/compass/ops/teams/{teamId}/on-call" help:"Link to the Compass page of the schedules, absolute or relative to the site URL, where {id} and {teamId} are replaced; empty to leave the schedules without link"`
+	MetricsPort                 int             `default:"9090" help:"Port of the Prometheus metrics listener, 0 to disable it"`
+	MetricsHost                 string          `help:"Host of the Prometheus metrics listener"`
+	Tracing                     bool            `help:"Export traces over OTLP/HTTP, configured with the standard OTEL_EXPORTER_OTLP_* variables"`
+	TracingSampleRatio          float64         `default:"1" help:"Fraction of the traces exported"`
+	RefreshInterval             time.Duration   `default:"60s" help:"How often the on-call snapshot is rebuilt, 0 to call Compass on every request"`
+	SlackSigningSecret          string          `help:"Slack signing secret, required by the http transport"`
+	SlackPreviousSigningSecrets []string        `help:"Former Slack signing secrets still accepted, as secret[@expiry] with an RFC 3339 time or a date"`
+	SlackClientId               string          `help:"Slack app client ID, enables the OAuth install flow"`
+	SlackClientSecret           string          `help:"Slack app client secret"`
+	SlackRedirectUrl            string          `help:"Slack OAuth redirect URL"`
+	SlackTokenStore             string          `help:"Path of the file storing workspace installations, kept in memory when empty"`
+	SlackAppToken               string          `help:"Slack app-level token (xapp-...), required by the socket transport"`
+	SettingsStore               string          `help:"Path of the file storing channel settings, kept in memory when empty"`
+	Admins                      []string        `help:"Slack user IDs allowed to change the settings, on top of the workspace admins"`
+	Locale                      string          `default:"en" help:"Language of the answers when the Slack locale of the user can't be looked up"`
+	Timezone                    string          `default:"UTC" help:"Timezone of the answers when the Slack timezone of the user can't be looked up"`
+	AuditFile                   string          `help:"Path of the JSON lines file the audit log is appended to"`
+	AuditStdout                 bool            `help:"Write the audit log to stdout as JSON lines"`
+	AuditWebhookUrl             string          `help:"URL the audit log entries are posted to as JSON"`
+	ApiKeys                     []string        `help:"Keys of the REST API clients, as [name:]key, sent as bearer tokens or in the X-API-Key header; the API is disabled when empty"`
+}
+
+type runner interface {
+	Start() error
+	Stop(ctx context.Context) error
+}
+
+func (r RunCMD) serverOptions() ([]server.ServerOption, error) {
+	var settings store.SettingsStore = store.NewMemorySettingsStore()
+	if r.SettingsStore != "" {
+		fileStore, err := store.NewFileSettingsStore(r.SettingsStore)
+		if err != nil {
+			return nil, fmt.Errorf("store.NewFileSettingsStore: %v", err)
+		}
+		settings = fileStore
+	}
+
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return nil, fmt.Errorf("--timezone: %v", err)
+	}
+
+	opts := []server.ServerOption{
+		server.WithSettingsStore(settings),
+		server.WithAdmins(r.Admins),
+		server.WithDefaultLocalizer(slackmsg.NewLocalizer(r.Locale, r.Timezone)),
+	}
+
+	if len(r.SlackPreviousSigningSecrets) > 0 {
+		previous := make([]middleware.SigningSecret, 0, len(r.SlackPreviousSigningSecrets))
+		for _, value := range r.SlackPreviousSigningSecrets {
+			secret, err := middleware.ParseSigningSecret(value)
+			if err != nil {
+				return nil, fmt.Errorf("--slack-previous-signing-secrets: %v", err)
+			}
+			previous = append(previous, secret)
+		}
+		opts = append(opts, server.WithPreviousSigningSecrets(previous...))
+	}
+
+	if len(r.ApiKeys) > 0 {
+		keys := make([]server.APIKey, 0, len(r.ApiKeys))
+		for _, value := range r.ApiKeys {
+			key, err := server.ParseAPIKey(value)
+			if err != nil {
+				return nil, fmt.Errorf("--api-keys: %v", err)
+			}
+			keys = append(keys, key)
+		}
+		opts = append(opts, server.WithAPIKeys(keys...))
+	}
+
+	if r.Config != "" {
+		file, err := config.Load(string(r.Config))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, server.WithFileSettings(fileSettings(file)))
+	}
+
+	if r.SlackClientId == "" && r.SlackTokenStore == "" {
+		return opts, nil
+	}
+
+	var tokens store.TokenStore = store.NewMemoryTokenStore()
+	if r.SlackTokenStore != "" {
+		fileStore, err := store.NewFileTokenStore(r.SlackTokenStore)
+		if err != nil {
+			return nil, fmt.Errorf("store.NewFileTokenStore: %v", err)
+		}
+		tokens = fileStore
+	}
+	opts = append(opts, server.WithTokenStore(tokens))
+
+	if r.SlackClientId != "" {
+		if r.SlackClientSecret == "" {
+			return nil, fmt.Errorf("--slack-client-secret is required with --slack-client-id")
+		}
+		opts = append(opts, server.WithOAuth(server.OAuthConfig{
+			ClientID:     r.SlackClientId,
+			ClientSecret: r.SlackClientSecret,
+			RedirectURL:  r.SlackRedirectUrl,
+		}))
+	}
+
+	return opts, nil
+}
+
+// auditLog builds the audit log with the sinks enabled by the flags. The last
+// entries of the file are loaded so `/oncall audit` survives a restart.
+func (r RunCMD) auditLog() (*audit.Log, error) {
+	var opts []audit.Option
+	if r.AuditFile != "" {
+		history, err := audit.ReadFile(r.AuditFile, audit.DefaultRecentEntries)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := audit.NewFileSink(r.AuditFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithHistory(history), audit.WithSink(sink))
+	}
+	if r.AuditStdout {
+		opts = append(opts, audit.WithSink(audit.NewWriterSink(os.Stdout)))
+	}
+	if r.AuditWebhookUrl != "" {
+		opts = append(opts, audit.WithSink(audit.NewWebhookSink(r.AuditWebhookUrl, &http.Client{})))
+	}
+	return audit.NewLog(opts...), nil
+}
+
+// scheduleUrl resolves the link to the schedules against the site URL.
+func (r RunCMD) scheduleUrl() string {
+	if !strings.HasPrefix(r.ScheduleUrl, "/") {
+		return r.ScheduleUrl
+	}
+	return strings.TrimSuffix(r.AtlassianSiteUrl, "/") + r.ScheduleUrl
 }
 
 func (r RunCMD) Run(cli *Cli) error {
-	app := app.NewApp(
-		api.NewCompassClient(r.AtlassianApiUser, r.AtlassianApiKey, r.AtlassianCloudId),
-		api.NewJiraClient(r.AtlassianApiUser, r.AtlassianApiKey),
+	if r.Tracing {
+		shutdownTracing, err := tracing.Setup(context.Background(), r.TracingSampleRatio)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error("Error flushing traces", "error", err)
+			}
+		}()
+	}
+
+	retryPolicy := api.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = r.AtlassianAttempts
+	retryPolicy.Budget = r.AtlassianBudget
+	// Compass and Jira share the transport, and thus the connection pool and
+	// the per-host rate limits. Every retry attempt goes through the limiter,
+	// and is measured and traced without the time spent waiting for it.
+	atlassianClient := &http.Client{
+		Transport: api.NewRetryTransport(
+			api.NewRateLimitTransport(
+				tracing.Transport(metrics.InstrumentTransport(http.DefaultTransport)),
+				r.AtlassianRate, r.AtlassianBurst,
+			),
+			retryPolicy,
+		),
+	}
+
+	jira, err := api.NewJiraClient(
+		r.AtlassianApiUser, r.AtlassianApiKey,
+		api.WithJiraBaseUrl(r.AtlassianSiteUrl),
+		api.WithJiraHttpClient(atlassianClient),
+	)
+	if err != nil {
+		return fmt.Errorf("api.NewJiraClient: %w", err)
+	}
+
+	oncall := app.NewApp(
+		api.NewCompassClient(
+			r.AtlassianApiUser, r.AtlassianApiKey, r.AtlassianCloudId,
+			api.WithBaseUrl(r.AtlassianApiUrl),
+			api.WithHttpClient(atlassianClient),
+		),
+		jira,
+		app.WithCallTimeout(r.AtlassianTimeout),
+		app.WithConcurrency(r.AtlassianWorkers),
+		app.WithScheduleURL(r.scheduleUrl()),
 	)
 
-	srv := server.NewServer(app, r.Host, r.Port, r.SlackSigningSecret)
+	opts, err := r.serverOptions()
+	if err != nil {
+		return err
+	}
+
+	auditLog, err := r.auditLog()
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+	opts = append(opts, server.WithAuditLog(auditLog))
+
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	if r.RefreshInterval > 0 {
+		refresher := app.NewRefresher(oncall, r.RefreshInterval)
+		go refresher.Run(refreshCtx)
+		opts = append(opts, server.WithRefresher(refresher))
+	}
+
+	httpServer := server.NewServer(oncall, r.Host, r.Port, r.SlackSigningSecret, opts...)
+
+	var srv runner = httpServer
+	switch r.Transport {
+	case "http":
+		if r.SlackSigningSecret == "" {
+			return fmt.Errorf("--slack-signing-secret is required with the http transport")
+		}
+	case "socket":
+		if r.SlackAppToken == "" {
+			return fmt.Errorf("--slack-app-token is required with the socket transport")
+		}
+		srv = server.NewSocketModeRunner(httpServer, r.SlackAppToken)
+	}
+
 	if err := srv.Start(); err != nil {
 		slog.Error("Error starting server", slog.String("error", err.Error()))
 		return fmt.Errorf("Error starting server: %v", err)
 	}
 
+	var metricsServer *metrics.Server
+	if r.MetricsPort != 0 {
+		// The probes are also served next to the metrics, the main listener
+		// does not run with the socket transport, and so is the REST API then
+		probes := httpServer.ProbeHandler()
+		metricsOpts := []metrics.ServerOption{
+			metrics.WithHandler("/healthz", probes),
+			metrics.WithHandler("/readyz", probes),
+		}
+		if api := httpServer.APIHandler(); api != nil && r.Transport == "socket" {
+			metricsOpts = append(metricsOpts, metrics.WithHandler("/api/", api))
+		}
+		metricsServer = metrics.NewServer(r.MetricsHost, r.MetricsPort, metricsOpts...)
+		if err := metricsServer.Start(); err != nil {
+			return fmt.Errorf("Error starting metrics server: %v", err)
+		}
+	}
+
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	select {
-	case s := <-signalChan:
+	for s := range signalChan {
+		if s == syscall.SIGHUP {
+			r.reloadConfig(httpServer)
+			continue
+		}
 		slog.Info("Termination signal received, shutting down...", slog.String("signal", s.String()))
+		break
 	}
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(cli.Timeout)*time.Millisecond)
 	defer shutdownCancel()
 
-	err := srv.Stop(shutdownCtx)
+	err = srv.Stop(shutdownCtx)
 	if err != nil {
 		return fmt.Errorf("s.Stop: %v", err)
 	}
+	if metricsServer != nil {
+		if err := metricsServer.Stop(shutdownCtx); err != nil {
+			return fmt.Errorf("metricsServer.Stop: %v", err)
+		}
+	}
 
 	slog.Info("Shut down complete")
 	return nil
 }
 
+// reloadConfig applies the aliases and channel defaults of the configuration
+// file. Flags are only read at startup, changing them requires a restart.
+func (r RunCMD) reloadConfig(srv *server.Server) {
+	if r.Config == "" {
+		slog.Warn("SIGHUP received without configuration file, ignoring")
+		return
+	}
+
+	file, err := config.Load(string(r.Config))
+	if err != nil {
+		slog.Error("Error reloading configuration, keeping the current one", "error", err)
+		return
+	}
+
+	srv.ReloadFileSettings(fileSettings(file))
+	slog.Info("Configuration reloaded", "path", string(r.Config))
+}
+
+func fileSettings(file *config.File) server.FileSettings {
+	settings := server.FileSettings{
+		Aliases: file.Aliases,
+	}
+	for _, mapping := range file.Channels {
+		settings.Channels = append(settings.Channels, store.ChannelSettings{
+			TeamID:     mapping.Team,
+			ChannelID:  mapping.Channel,
+			Schedules:  mapping.Schedules,
+			Visibility: mapping.Visibility,
+		})
+	}
+	for _, rule := range file.Policies {
+		settings.Policies = append(settings.Policies, policy.Rule{
+			Action:     rule.Action,
+			Team:       rule.Team,
+			Channels:   rule.Channels,
+			Users:      rule.Users,
+			Usergroups: rule.Usergroups,
+			Admins:     rule.Admins,
+		})
+	}
+	return settings
+}
+
 func main() {
-	slog.Info("Starting CLI")
 	var cli Cli
-	kctx := kong.Parse(&cli, kong.DefaultEnvars(""))
-	err := kctx.Run()
+	// The --config flag only loads the file when given on the command line,
+	// the CONFIG environment variable has to be loaded upfront.
+	var configPaths []string
+	if path := os.Getenv("CONFIG"); path != "" {
+		configPaths = append(configPaths, path)
+	}
+	kctx := kong.Parse(&cli, kong.DefaultEnvars(""), kong.Configuration(config.Loader, configPaths...))
+
+	handler, err := logging.NewHandler(cli.LogFormat, os.Stderr, cli.LogLevel)
+	kctx.FatalIfErrorf(err)
+	slog.SetDefault(slog.New(tracing.NewLogHandler(handler)))
+
+	slog.Info("Starting CLI")
+	err = kctx.Run()
 	kctx.FatalIfErrorf(err)
 }
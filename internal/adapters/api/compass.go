@@ -1,16 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 const (
-	baseUrl = "https://api.atlassian.com/compass/cloud"
+	defaultApiUrl = "https://api.atlassian.com"
+	compassPath   = "/compass/cloud"
 )
 
 // ClientOption allows for functional options to configure the CompassClient
@@ -23,10 +27,19 @@ func WithHttpClient(client *http.Client) ClientOption {
 	}
 }
 
+// WithBaseUrl sets the URL of the Atlassian API gateway, e.g. to point the
+// client to a local stand-in
+func WithBaseUrl(apiUrl string) ClientOption {
+	return func(c *CompassClient) {
+		c.apiUrl = apiUrl
+	}
+}
+
 type CompassClient struct {
 	user    string
 	apiKey  string
 	cloudId string
+	apiUrl  string
 	client  *http.Client
 }
 
@@ -35,7 +48,8 @@ func NewCompassClient(user, apiKey, cloudId string, opts ...ClientOption) *Compa
 		user:    user,
 		apiKey:  apiKey,
 		cloudId: cloudId,
-		client:  &http.Client{},
+		apiUrl:  defaultApiUrl,
+		client:  NewRetryClient(),
 	}
 
 	for _, opt := range opts {
@@ -49,14 +63,15 @@ type compassApiRequest struct {
 	Endpoint string
 	Method   string
 	Body     io.Reader
+	Query    url.Values
 }
 
 type scheduleAPIResponse struct {
 	Values []Schedule `json:"values"`
 }
 
-func (c *CompassClient) doRequest(req compassApiRequest) (*http.Response, error) {
-	endpoint, err := url.JoinPath(baseUrl, c.cloudId, "/ops/v1")
+func (c *CompassClient) doRequest(ctx context.Context, req compassApiRequest) (*http.Response, error) {
+	endpoint, err := url.JoinPath(c.apiUrl, compassPath, c.cloudId, "/ops/v1")
 	if err != nil {
 		return nil, fmt.Errorf("error joining base URL: %w", err)
 	}
@@ -66,11 +81,12 @@ func (c *CompassClient) doRequest(req compassApiRequest) (*http.Response, error)
 		return nil, fmt.Errorf("error joining API URL: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(req.Method, endpoint, req.Body)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, endpoint, req.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
+	httpReq.URL.RawQuery = req.Query.Encode()
 	httpReq.SetBasicAuth(c.user, c.apiKey)
 	httpReq.Header.Set("Accept", "application/json")
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -83,14 +99,14 @@ func (c *CompassClient) doRequest(req compassApiRequest) (*http.Response, error)
 	return res, nil
 }
 
-func (c *CompassClient) GetSchedules() ([]Schedule, error) {
+func (c *CompassClient) GetSchedules(ctx context.Context) ([]Schedule, error) {
 	req := compassApiRequest{
 		Endpoint: "schedules",
 		Method:   "GET",
 		Body:     nil,
 	}
 
-	res, err := c.doRequest(req)
+	res, err := c.doRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
@@ -98,7 +114,7 @@ func (c *CompassClient) GetSchedules() ([]Schedule, error) {
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("error: received status code %d, body: %s", res.StatusCode, body)
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: string(body)}
 	}
 
 	body, err := io.ReadAll(res.Body)
@@ -115,14 +131,14 @@ func (c *CompassClient) GetSchedules() ([]Schedule, error) {
 	return response.Values, nil
 }
 
-func (c *CompassClient) GetOnCallSchedules(scheduleID string) (*OnCallResponse, error) {
+func (c *CompassClient) GetOnCallSchedules(ctx context.Context, scheduleID string) (*OnCallResponse, error) {
 	req := compassApiRequest{
 		Endpoint: fmt.Sprintf("schedules/%s/on-calls", scheduleID),
 		Method:   "GET",
 		Body:     nil,
 	}
 
-	res, err := c.doRequest(req)
+	res, err := c.doRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
@@ -130,7 +146,7 @@ func (c *CompassClient) GetOnCallSchedules(scheduleID string) (*OnCallResponse,
 
 	if res.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("error: received status code %d, body: %s", res.StatusCode, body)
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: string(body)}
 	}
 
 	body, err := io.ReadAll(res.Body)
@@ -147,3 +163,36 @@ func (c *CompassClient) GetOnCallSchedules(scheduleID string) (*OnCallResponse,
 
 	return &onCallResponse, nil
 }
+
+// GetScheduleTimeline returns the shifts of the schedule over the given number
+// of days, starting at from.
+func (c *CompassClient) GetScheduleTimeline(ctx context.Context, scheduleID string, from time.Time, days int) (*TimelineResponse, error) {
+	req := compassApiRequest{
+		Endpoint: fmt.Sprintf("schedules/%s/timeline", scheduleID),
+		Method:   "GET",
+		Body:     nil,
+		Query: url.Values{
+			"date":         {from.UTC().Format(time.RFC3339)},
+			"interval":     {strconv.Itoa(days)},
+			"intervalUnit": {"days"},
+		},
+	}
+
+	res, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: string(body)}
+	}
+
+	var timeline TimelineResponse
+	if err := json.NewDecoder(res.Body).Decode(&timeline); err != nil {
+		return nil, fmt.Errorf("error decoding response body: %w", err)
+	}
+
+	return &timeline, nil
+}
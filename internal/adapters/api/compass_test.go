@@ -1,10 +1,12 @@
 package api
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/metriodev/pompiers/internal/pkg/utils"
 )
@@ -13,6 +15,7 @@ const (
 	mockCloudId = "mock-cloud-id"
 	mockUser    = "mock-user"
 	mockApiKey  = "mock-api-key"
+	mockSiteUrl = "https://mock-site.atlassian.net"
 )
 
 func TestCompassClient(t *testing.T) {
@@ -42,7 +45,7 @@ func TestCompassClient(t *testing.T) {
 
 	client := NewCompassClient(mockUser, mockApiKey, mockCloudId, WithHttpClient(mockClient))
 
-	schedules, err := client.GetSchedules()
+	schedules, err := client.GetSchedules(t.Context())
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -57,3 +60,89 @@ func TestCompassClient(t *testing.T) {
 		t.Errorf("expected schedule name 'Test Schedule', got '%s'", schedules[0].Name)
 	}
 }
+
+func TestCompassClient_BaseUrl(t *testing.T) {
+	mockClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			expected := "http://localhost:1234/compass/cloud/" + mockCloudId + "/ops/v1/schedules"
+			if req.URL.String() != expected {
+				t.Errorf("expected request to '%s', got '%s'", expected, req.URL.String())
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"values": []}`)),
+			}, nil
+		}),
+	}
+
+	client := NewCompassClient(mockUser, mockApiKey, mockCloudId, WithHttpClient(mockClient), WithBaseUrl("http://localhost:1234/"))
+
+	if _, err := client.GetSchedules(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCompassClient_StatusError(t *testing.T) {
+	mockClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Body:       io.NopCloser(strings.NewReader(`{"message": "Unauthorized"}`)),
+			}, nil
+		}),
+	}
+
+	client := NewCompassClient(mockUser, mockApiKey, mockCloudId, WithHttpClient(mockClient))
+
+	_, err := client.GetSchedules(t.Context())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a status error with code 401, got %v", err)
+	}
+}
+
+func TestCompassClient_GetScheduleTimeline(t *testing.T) {
+	mockClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/schedules/schedule-1/timeline") {
+				t.Errorf("unexpected request to '%s'", req.URL.Path)
+			}
+			if req.URL.Query().Get("interval") != "14" || req.URL.Query().Get("intervalUnit") != "days" {
+				t.Errorf("unexpected query '%s'", req.URL.RawQuery)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{
+					"startDate": "2025-01-01T00:00:00Z",
+					"endDate": "2025-01-15T00:00:00Z",
+					"finalTimeline": {"rotations": [
+						{"id": "primary", "periods": [
+							{"startDate": "2024-12-30T09:00:00Z", "endDate": "2025-01-01T09:00:00Z", "type": "default"},
+							{"startDate": "2025-01-01T09:00:00Z", "endDate": "2025-01-02T09:00:00Z", "type": "default"}
+						]},
+						{"id": "secondary", "periods": [
+							{"startDate": "2024-12-25T00:00:00Z", "endDate": "2025-01-15T00:00:00Z", "type": "default"}
+						]}
+					]}
+				}`)),
+			}, nil
+		}),
+	}
+
+	client := NewCompassClient(mockUser, mockApiKey, mockCloudId, WithHttpClient(mockClient))
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeline, err := client.GetScheduleTimeline(t.Context(), "schedule-1", from, 14)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The secondary shift outlasts the timeline, its end is unknown
+	expected := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	if end := timeline.CurrentShiftEnd(from); !end.Equal(expected) {
+		t.Errorf("expected current shift to end at %v, got %v", expected, end)
+	}
+	if end := timeline.CurrentShiftEnd(from.AddDate(0, 0, 10)); !end.IsZero() {
+		t.Errorf("expected no known shift end, got %v", end)
+	}
+}
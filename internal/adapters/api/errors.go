@@ -0,0 +1,16 @@
+package api
+
+import "fmt"
+
+// StatusError is returned when an Atlassian API answers with an unexpected
+// status code.
+type StatusError struct {
+	StatusCode int
+	// Message is the error message of the response, or its raw body when the
+	// API doesn't describe the error
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: received status code %d, message: %s", e.StatusCode, e.Message)
+}
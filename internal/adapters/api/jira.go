@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,7 +11,7 @@ import (
 )
 
 const (
-	apiBaseUrl = "https://nasdaq-metrio.atlassian.net/rest/api/3"
+	jiraApiPath = "/rest/api/3"
 )
 
 // ClientOption allows for functional options to configure the JiraClient
@@ -23,25 +24,38 @@ func WithJiraHttpClient(client *http.Client) JiraClientOption {
 	}
 }
 
+// WithJiraBaseUrl sets the URL of the Atlassian site, e.g.
+// https://your-domain.atlassian.net
+func WithJiraBaseUrl(siteUrl string) JiraClientOption {
+	return func(j *JiraClient) {
+		j.siteUrl = siteUrl
+	}
+}
+
 type JiraClient struct {
-	user   string
-	apiKey string
-	client *http.Client
+	user    string
+	apiKey  string
+	siteUrl string
+	client  *http.Client
 }
 
-// Replace current constructor with this one
-func NewJiraClient(user, apiKey string, opts ...JiraClientOption) *JiraClient {
+// NewJiraClient returns a client of the Jira API of the Atlassian site set
+// with WithJiraBaseUrl, which has no default.
+func NewJiraClient(user, apiKey string, opts ...JiraClientOption) (*JiraClient, error) {
 	client := &JiraClient{
 		user:   user,
 		apiKey: apiKey,
-		client: &http.Client{},
+		client: NewRetryClient(),
 	}
 
 	for _, opt := range opts {
 		opt(client)
 	}
 
-	return client
+	if client.siteUrl == "" {
+		return nil, fmt.Errorf("the Atlassian site URL is required")
+	}
+	return client, nil
 }
 
 type User struct {
@@ -49,6 +63,21 @@ type User struct {
 	AccountType string `json:"accountType"`
 	Active      bool   `json:"active"`
 	DisplayName string `json:"displayName"`
+	// AvatarUrls are the avatars of the user by size, e.g. "48x48"
+	AvatarUrls map[string]string `json:"avatarUrls"`
+}
+
+// avatarSizes are the sizes of the Jira avatars, largest first
+var avatarSizes = []string{"48x48", "32x32", "24x24", "16x16"}
+
+// AvatarUrl returns the largest avatar of the user, empty when there is none.
+func (u User) AvatarUrl() string {
+	for _, size := range avatarSizes {
+		if avatar := u.AvatarUrls[size]; avatar != "" {
+			return avatar
+		}
+	}
+	return ""
 }
 
 type jiraApiRequest struct {
@@ -69,13 +98,13 @@ type apiError struct {
 	Data    string `json:"data"`
 }
 
-func (c *JiraClient) doRequest(req jiraApiRequest) (*http.Response, error) {
-	apiUrl, err := url.JoinPath(apiBaseUrl, req.Endpoint)
+func (c *JiraClient) doRequest(ctx context.Context, req jiraApiRequest) (*http.Response, error) {
+	apiUrl, err := url.JoinPath(c.siteUrl, jiraApiPath, req.Endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("error joining API URL: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(req.Method, apiUrl, req.Body)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, apiUrl, req.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -93,8 +122,8 @@ func (c *JiraClient) doRequest(req jiraApiRequest) (*http.Response, error) {
 	return res, nil
 }
 
-func (c *JiraClient) GetUserInfo(accountID string) (*User, error) {
-	slog.Info("Fetching user info", slog.String("accountID", accountID))
+func (c *JiraClient) GetUserInfo(ctx context.Context, accountID string) (*User, error) {
+	slog.DebugContext(ctx, "Fetching user info", slog.String("accountID", accountID))
 	req := jiraApiRequest{
 		Endpoint: "user",
 		Query:    url.Values{"accountId": {accountID}},
@@ -102,7 +131,7 @@ func (c *JiraClient) GetUserInfo(accountID string) (*User, error) {
 		Body:     nil,
 	}
 
-	res, err := c.doRequest(req)
+	res, err := c.doRequest(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
@@ -113,7 +142,8 @@ func (c *JiraClient) GetUserInfo(accountID string) (*User, error) {
 		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil {
 			return nil, fmt.Errorf("error decoding error response: %w", err)
 		}
-		slog.Error(
+		slog.ErrorContext(
+			ctx,
 			"Error fetching user info",
 			slog.String("httpCope", res.Status),
 			slog.String("accountID", accountID),
@@ -124,7 +154,35 @@ func (c *JiraClient) GetUserInfo(accountID string) (*User, error) {
 				slog.String("data", apiErr.ApiError.Data),
 			),
 		)
-		return nil, fmt.Errorf("error: received status code %d, message: %s", res.StatusCode, apiErr.ApiError.Message)
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: apiErr.ApiError.Message}
+	}
+
+	var user User
+	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("error decoding response body: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetMyself returns the user the client is authenticated as. It is used to
+// check that the credentials are valid.
+func (c *JiraClient) GetMyself(ctx context.Context) (*User, error) {
+	req := jiraApiRequest{
+		Endpoint: "myself",
+		Method:   "GET",
+		Body:     nil,
+	}
+
+	res, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return nil, &StatusError{StatusCode: res.StatusCode, Message: string(body)}
 	}
 
 	var user User
@@ -30,21 +30,42 @@ func buildMockHttpClient(t *testing.T, res *http.Response) *http.Client {
 	}
 }
 
+// givenJiraClient returns a client of the mock site.
+func givenJiraClient(t *testing.T, opts ...JiraClientOption) *JiraClient {
+	t.Helper()
+
+	client, err := NewJiraClient(mockUser, mockApiKey, append([]JiraClientOption{WithJiraBaseUrl(mockSiteUrl)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create the Jira client: %v", err)
+	}
+	return client
+}
+
+func TestNewJiraClient_RequiresSiteUrl(t *testing.T) {
+	if _, err := NewJiraClient(mockUser, mockApiKey); err == nil {
+		t.Error("expected an error without site URL")
+	}
+}
+
 func TestGetUserInfo(t *testing.T) {
 	mockResponse := `{
 		"accountId": "user-1",
 		"accountType": "atlassian",
 		"active": true,
-		"displayName": "Test User"
+		"displayName": "Test User",
+		"avatarUrls": {
+			"16x16": "https://avatar.example/16.png",
+			"48x48": "https://avatar.example/48.png"
+		}
 	}`
 
 	mockClient := buildMockHttpClient(t, &http.Response{
 		StatusCode: http.StatusOK,
 		Body:       io.NopCloser(strings.NewReader(mockResponse)),
 	})
-	client := NewJiraClient(mockUser, mockApiKey, WithJiraHttpClient(mockClient))
+	client := givenJiraClient(t, WithJiraHttpClient(mockClient))
 
-	user, err := client.GetUserInfo("user-1")
+	user, err := client.GetUserInfo(t.Context(), "user-1")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -58,6 +79,9 @@ func TestGetUserInfo(t *testing.T) {
 	if !user.Active {
 		t.Errorf("expected user to be active")
 	}
+	if user.AvatarUrl() != "https://avatar.example/48.png" {
+		t.Errorf("expected the largest avatar, got '%s'", user.AvatarUrl())
+	}
 }
 
 func TestGetUserInfo_NotFound(t *testing.T) {
@@ -65,9 +89,9 @@ func TestGetUserInfo_NotFound(t *testing.T) {
 		StatusCode: http.StatusNotFound,
 		Body:       io.NopCloser(strings.NewReader(`{"errorMessages":["User does not exist"]}`)),
 	})
-	client := NewJiraClient(mockUser, mockApiKey, WithJiraHttpClient(mockClient))
+	client := givenJiraClient(t, WithJiraHttpClient(mockClient))
 
-	_, err := client.GetUserInfo("nonexistent-user")
+	_, err := client.GetUserInfo(t.Context(), "nonexistent-user")
 	if err == nil {
 		t.Fatal("expected error for status code 404, got nil")
 	}
@@ -86,9 +110,9 @@ func TestGetUserInfo_InvalidJSON(t *testing.T) {
 		StatusCode: http.StatusOK,
 		Body:       io.NopCloser(strings.NewReader(invalidJSON)),
 	})
-	client := NewJiraClient(mockUser, mockApiKey, WithJiraHttpClient(mockClient))
+	client := givenJiraClient(t, WithJiraHttpClient(mockClient))
 
-	_, err := client.GetUserInfo("user-1")
+	_, err := client.GetUserInfo(t.Context(), "user-1")
 	if err == nil {
 		t.Fatal("expected error for invalid JSON, got nil")
 	}
@@ -96,3 +120,36 @@ func TestGetUserInfo_InvalidJSON(t *testing.T) {
 		t.Errorf("expected error message to contain 'error decoding response body', got: %v", err)
 	}
 }
+
+func TestGetUserInfo_BaseUrl(t *testing.T) {
+	mockClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			expected := "http://localhost:1234/rest/api/3/user?accountId=user-1"
+			if req.URL.String() != expected {
+				t.Errorf("expected request to '%s', got '%s'", expected, req.URL.String())
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"accountId": "user-1"}`)),
+			}, nil
+		}),
+	}
+	client := givenJiraClient(t, WithJiraHttpClient(mockClient), WithJiraBaseUrl("http://localhost:1234"))
+
+	if _, err := client.GetUserInfo(t.Context(), "user-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGetMyself_Unauthorized(t *testing.T) {
+	mockClient := buildMockHttpClient(t, &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Body:       io.NopCloser(strings.NewReader(`Client must be authenticated to access this resource.`)),
+	})
+	client := givenJiraClient(t, WithJiraHttpClient(mockClient))
+
+	_, err := client.GetMyself(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "status code 401") {
+		t.Errorf("expected error with status code 401, got: %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package api
 
+import "time"
+
 type Schedule struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
@@ -17,3 +19,47 @@ type OnCallParticipant struct {
 type OnCallResponse struct {
 	OnCallParticipants []OnCallParticipant `json:"onCallParticipants"`
 }
+
+type TimelinePeriod struct {
+	StartDate time.Time         `json:"startDate"`
+	EndDate   time.Time         `json:"endDate"`
+	Type      string            `json:"type"`
+	Responder OnCallParticipant `json:"responder"`
+}
+
+type TimelineRotation struct {
+	ID      string           `json:"id"`
+	Name    string           `json:"name"`
+	Periods []TimelinePeriod `json:"periods"`
+}
+
+type Timeline struct {
+	Rotations []TimelineRotation `json:"rotations"`
+}
+
+type TimelineResponse struct {
+	StartDate     time.Time `json:"startDate"`
+	EndDate       time.Time `json:"endDate"`
+	FinalTimeline Timeline  `json:"finalTimeline"`
+}
+
+// CurrentShiftEnd returns the end of the earliest shift running at the given
+// time. It is zero when no shift is running or when the shift outlasts the
+// timeline, in which case its end is unknown.
+func (t TimelineResponse) CurrentShiftEnd(at time.Time) time.Time {
+	var end time.Time
+	for _, rotation := range t.FinalTimeline.Rotations {
+		for _, period := range rotation.Periods {
+			if period.StartDate.After(at) || !period.EndDate.After(at) {
+				continue
+			}
+			if !t.EndDate.IsZero() && !period.EndDate.Before(t.EndDate) {
+				continue
+			}
+			if end.IsZero() || period.EndDate.Before(end) {
+				end = period.EndDate
+			}
+		}
+	}
+	return end
+}
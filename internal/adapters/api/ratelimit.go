@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitTransport throttles the outgoing requests with a token bucket per
+// host, so Compass (api.atlassian.com) and Jira (the site) have their own
+// budget.
+type RateLimitTransport struct {
+	next  http.RoundTripper
+	rate  rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimitTransport allows requestsPerSecond requests per host on
+// average, with bursts of up to burst requests. A zero rate disables the
+// limit.
+func NewRateLimitTransport(next http.RoundTripper, requestsPerSecond float64, burst int) *RateLimitTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	limit := rate.Limit(requestsPerSecond)
+	if requestsPerSecond <= 0 {
+		limit = rate.Inf
+	}
+
+	return &RateLimitTransport{
+		next:     next,
+		rate:     limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter(req.URL.Host).Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *RateLimitTransport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limiter, ok := t.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(t.rate, t.burst)
+		t.limiters[host] = limiter
+	}
+	return limiter
+}
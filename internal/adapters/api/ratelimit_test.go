@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+)
+
+func givenRateLimitTransport(requestsPerSecond float64, burst int) *RateLimitTransport {
+	next := utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+		}, nil
+	})
+	return NewRateLimitTransport(next, requestsPerSecond, burst)
+}
+
+func doRateLimitedRequest(ctx context.Context, t *testing.T, transport http.RoundTripper, host string) error {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+host+"/rest/api/3/user", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	_, err = transport.RoundTrip(req)
+	return err
+}
+
+func TestRateLimitTransport_ThrottlesPerHost(t *testing.T) {
+	transport := givenRateLimitTransport(20, 1)
+
+	start := time.Now()
+	for range 3 {
+		if err := doRateLimitedRequest(t.Context(), t, transport, "api.atlassian.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// The first request uses the burst, the next two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected requests to be throttled, took %v", elapsed)
+	}
+
+	start = time.Now()
+	if err := doRateLimitedRequest(t.Context(), t, transport, "example.atlassian.net"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("expected other hosts to have their own bucket, took %v", elapsed)
+	}
+}
+
+func TestRateLimitTransport_Cancellation(t *testing.T) {
+	transport := givenRateLimitTransport(0.1, 1)
+
+	if err := doRateLimitedRequest(t.Context(), t, transport, "api.atlassian.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	err := doRateLimitedRequest(ctx, t, transport, "api.atlassian.com")
+	if err == nil {
+		t.Fatal("expected an error when the wait exceeds the deadline, got nil")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && !strings.Contains(err.Error(), "exceed context deadline") {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
+
+func TestRateLimitTransport_Unlimited(t *testing.T) {
+	transport := givenRateLimitTransport(0, 0)
+
+	start := time.Now()
+	for range 50 {
+		if err := doRateLimitedRequest(t.Context(), t, transport, "api.atlassian.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected no throttling, took %v", elapsed)
+	}
+}
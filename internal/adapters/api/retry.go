@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures the RetryTransport.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the first backoff delay, doubled on
+	// every attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delays. Retry-After values are waited for in
+	// full, within the budget.
+	MaxDelay time.Duration
+	// Budget is the total time allowed for all the attempts of a request.
+	// No retry is attempted when waiting would exceed it.
+	Budget time.Duration
+}
+
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   250 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Budget:      15 * time.Second,
+	}
+)
+
+// RetryTransport retries idempotent requests throttled by Atlassian (429) or
+// failing with a transient error (502, 503, 504 or a network error). It waits
+// for the Retry-After delay when the response has one and uses an
+// exponential backoff with full jitter otherwise. Responses asking to wait
+// past the budget or the deadline of the request are returned as is.
+type RetryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+
+	// sleep and jitter are replaced in the tests
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(max time.Duration) time.Duration
+}
+
+func NewRetryTransport(next http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	return &RetryTransport{
+		next:   next,
+		policy: policy,
+		sleep:  sleepContext,
+		jitter: func(max time.Duration) time.Duration {
+			if max <= 0 {
+				return 0
+			}
+			return rand.N(max)
+		},
+	}
+}
+
+// NewRetryClient returns an HTTP client retrying with the default policy. It
+// is the client used by the Compass and Jira clients unless one is provided.
+func NewRetryClient() *http.Client {
+	return &http.Client{Transport: NewRetryTransport(http.DefaultTransport, DefaultRetryPolicy)}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := t.next.RoundTrip(attemptReq)
+		if !shouldRetry(req.Context(), res, err) || attempt >= t.policy.MaxAttempts {
+			return res, err
+		}
+
+		delay := t.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(res); ok {
+			// Retrying earlier than asked would only be throttled again
+			delay = retryAfter
+		}
+		if !t.withinBudget(req.Context(), start, delay) {
+			slog.WarnContext(req.Context(), "Retry budget exhausted", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "delay", delay)
+			return res, err
+		}
+
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		slog.WarnContext(
+			req.Context(),
+			"Retrying Atlassian request",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", status,
+			"error", err,
+			"attempt", attempt,
+			"delay", delay,
+		)
+
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// withinBudget tells whether waiting for the delay leaves the budget and the
+// deadline of the request, if any, time for another attempt.
+func (t *RetryTransport) withinBudget(ctx context.Context, start time.Time, delay time.Duration) bool {
+	if t.policy.Budget > 0 && time.Since(start)+delay > t.policy.Budget {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+	return true
+}
+
+// backoff returns the delay before the next attempt, picked at random
+// between 0 and BaseDelay*2^(attempt-1).
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.policy.MaxDelay {
+		ceiling = t.policy.MaxDelay
+	}
+	return t.jitter(ceiling)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	// The body has to be sent again on every attempt
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rewindRequest returns the request to send for the given attempt, with a
+// fresh copy of the body.
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("error rewinding request body: %w", err)
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+// parseRetryAfter reads the Retry-After header, either a number of seconds or
+// an HTTP date.
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
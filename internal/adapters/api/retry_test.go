@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+)
+
+type mockAttempt struct {
+	status     int
+	retryAfter string
+	err        error
+}
+
+// givenRetryTransport returns a transport replaying the attempts in order,
+// along with the number of requests made and the delays waited for.
+func givenRetryTransport(t *testing.T, policy RetryPolicy, attempts ...mockAttempt) (*RetryTransport, *int, *[]time.Duration) {
+	t.Helper()
+
+	calls := 0
+	next := utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if calls >= len(attempts) {
+			t.Fatalf("unexpected attempt %d", calls+1)
+		}
+		attempt := attempts[calls]
+		calls++
+
+		if req.Body != nil {
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != "payload" {
+				t.Errorf("expected body 'payload' on attempt %d, got '%s'", calls, body)
+			}
+		}
+		if attempt.err != nil {
+			return nil, attempt.err
+		}
+		res := &http.Response{
+			StatusCode: attempt.status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+		}
+		if attempt.retryAfter != "" {
+			res.Header.Set("Retry-After", attempt.retryAfter)
+		}
+		return res, nil
+	})
+
+	var delays []time.Duration
+	transport := NewRetryTransport(next, policy)
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	transport.jitter = func(max time.Duration) time.Duration { return max }
+
+	return transport, &calls, &delays
+}
+
+func doRetryRequest(t *testing.T, transport http.RoundTripper, method string) (*http.Response, error) {
+	t.Helper()
+
+	var body io.Reader
+	if method != http.MethodGet {
+		body = strings.NewReader("payload")
+	}
+	req, err := http.NewRequest(method, "https://api.atlassian.com/compass/cloud/schedules", body)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	return transport.RoundTrip(req)
+}
+
+func TestRetryTransport_RetryAfter(t *testing.T) {
+	transport, calls, delays := givenRetryTransport(t, DefaultRetryPolicy,
+		mockAttempt{status: http.StatusTooManyRequests, retryAfter: "2"},
+		mockAttempt{status: http.StatusTooManyRequests, retryAfter: "1"},
+		mockAttempt{status: http.StatusOK},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK, got %d", res.StatusCode)
+	}
+	if *calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", *calls)
+	}
+	if len(*delays) != 2 || (*delays)[0] != 2*time.Second || (*delays)[1] != time.Second {
+		t.Errorf("expected Retry-After delays [2s 1s], got %v", *delays)
+	}
+}
+
+func TestRetryTransport_ExponentialBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Budget: time.Minute}
+	transport, calls, delays := givenRetryTransport(t, policy,
+		mockAttempt{status: http.StatusServiceUnavailable},
+		mockAttempt{status: http.StatusBadGateway},
+		mockAttempt{err: errors.New("connection reset by peer")},
+		mockAttempt{status: http.StatusGatewayTimeout},
+		mockAttempt{status: http.StatusOK},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK, got %d", res.StatusCode)
+	}
+	if *calls != 5 {
+		t.Errorf("expected 5 attempts, got %d", *calls)
+	}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, d := range expected {
+		if i >= len(*delays) || (*delays)[i] != d {
+			t.Fatalf("expected delays %v, got %v", expected, *delays)
+		}
+	}
+}
+
+func TestRetryTransport_MaxAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	transport, calls, _ := givenRetryTransport(t, policy,
+		mockAttempt{status: http.StatusTooManyRequests},
+		mockAttempt{status: http.StatusTooManyRequests},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the last response to be returned, got %d", res.StatusCode)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", *calls)
+	}
+}
+
+func TestRetryTransport_Budget(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Minute, Budget: 10 * time.Second}
+	transport, calls, delays := givenRetryTransport(t, policy,
+		mockAttempt{status: http.StatusTooManyRequests, retryAfter: "30"},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", res.StatusCode)
+	}
+	if *calls != 1 || len(*delays) != 0 {
+		t.Errorf("expected no retry past the budget, got %d attempts and delays %v", *calls, *delays)
+	}
+}
+
+func TestRetryTransport_RetryAfterPastMaxDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second, Budget: time.Minute}
+	transport, calls, delays := givenRetryTransport(t, policy,
+		mockAttempt{status: http.StatusServiceUnavailable, retryAfter: "10"},
+		mockAttempt{status: http.StatusOK},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status OK, got %d", res.StatusCode)
+	}
+	if *calls != 2 || len(*delays) != 1 || (*delays)[0] != 10*time.Second {
+		t.Errorf("expected the full Retry-After delay of 10s, got %d attempts and delays %v", *calls, *delays)
+	}
+}
+
+func TestRetryTransport_RetryAfterPastDeadline(t *testing.T) {
+	transport, calls, delays := givenRetryTransport(t, DefaultRetryPolicy,
+		mockAttempt{status: http.StatusTooManyRequests, retryAfter: "10"},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.atlassian.com/compass/cloud/schedules", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", res.StatusCode)
+	}
+	if *calls != 1 || len(*delays) != 0 {
+		t.Errorf("expected no retry past the deadline, got %d attempts and delays %v", *calls, *delays)
+	}
+}
+
+func TestRetryTransport_NonIdempotent(t *testing.T) {
+	transport, calls, _ := givenRetryTransport(t, DefaultRetryPolicy,
+		mockAttempt{status: http.StatusServiceUnavailable},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodPost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable || *calls != 1 {
+		t.Errorf("expected POST not to be retried, got status %d after %d attempts", res.StatusCode, *calls)
+	}
+}
+
+func TestRetryTransport_RewindsBody(t *testing.T) {
+	transport, calls, _ := givenRetryTransport(t, DefaultRetryPolicy,
+		mockAttempt{status: http.StatusServiceUnavailable},
+		mockAttempt{status: http.StatusOK},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodPut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || *calls != 2 {
+		t.Errorf("expected PUT to be retried, got status %d after %d attempts", res.StatusCode, *calls)
+	}
+}
+
+func TestRetryTransport_ClientErrorsNotRetried(t *testing.T) {
+	transport, calls, _ := givenRetryTransport(t, DefaultRetryPolicy,
+		mockAttempt{status: http.StatusNotFound},
+	)
+
+	res, err := doRetryRequest(t, transport, http.MethodGet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != http.StatusNotFound || *calls != 1 {
+		t.Errorf("expected 404 not to be retried, got status %d after %d attempts", res.StatusCode, *calls)
+	}
+}
+
+func TestCompassClient_RetriesThrottledRequests(t *testing.T) {
+	attempts := 0
+	next := utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"0"}},
+				Body:       io.NopCloser(strings.NewReader(`{}`)),
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"values": [{"id": "schedule-1", "name": "Test Schedule"}]}`)),
+		}, nil
+	})
+	httpClient := &http.Client{Transport: NewRetryTransport(next, DefaultRetryPolicy)}
+	client := NewCompassClient(mockUser, mockApiKey, mockCloudId, WithHttpClient(httpClient))
+
+	schedules, err := client.GetSchedules(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(schedules) != 1 || attempts != 2 {
+		t.Errorf("expected 1 schedule after 2 attempts, got %d after %d", len(schedules), attempts)
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileTokenStore keeps installations in a JSON file on disk. The whole file is
+// rewritten on every change, which is fine for the handful of workspaces the
+// bot is installed in.
+type FileTokenStore struct {
+	mu            sync.RWMutex
+	path          string
+	installations map[string]Installation
+}
+
+func NewFileTokenStore(path string) (*FileTokenStore, error) {
+	s := &FileTokenStore{
+		path:          path,
+		installations: make(map[string]Installation),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading token store %s: %w", path, err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.installations); err != nil {
+			return nil, fmt.Errorf("error decoding token store %s: %w", path, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (f *FileTokenStore) Get(teamID string) (Installation, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	installation, ok := f.installations[teamID]
+	if !ok {
+		return Installation{}, fmt.Errorf("installation for team %s: %w", teamID, ErrNotFound)
+	}
+	return installation, nil
+}
+
+func (f *FileTokenStore) Save(installation Installation) error {
+	if installation.TeamID == "" {
+		return fmt.Errorf("installation is missing a team ID")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, existed := f.installations[installation.TeamID]
+	f.installations[installation.TeamID] = installation
+	if err := f.flush(); err != nil {
+		if existed {
+			f.installations[installation.TeamID] = previous
+		} else {
+			delete(f.installations, installation.TeamID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *FileTokenStore) Delete(teamID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, existed := f.installations[teamID]
+	if !existed {
+		return nil
+	}
+	delete(f.installations, teamID)
+	if err := f.flush(); err != nil {
+		f.installations[teamID] = previous
+		return err
+	}
+	return nil
+}
+
+// writeJSONFile atomically replaces the file at path with the JSON encoding of v.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting permissions on %s: %w", tmp.Name(), err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+	return nil
+}
+
+// flush writes the installations to disk. Callers must hold the write lock.
+func (f *FileTokenStore) flush() error {
+	return writeJSONFile(f.path, f.installations)
+}
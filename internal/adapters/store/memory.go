@@ -0,0 +1,49 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryTokenStore keeps installations in memory. Installations are lost on restart.
+type MemoryTokenStore struct {
+	mu            sync.RWMutex
+	installations map[string]Installation
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		installations: make(map[string]Installation),
+	}
+}
+
+func (m *MemoryTokenStore) Get(teamID string) (Installation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	installation, ok := m.installations[teamID]
+	if !ok {
+		return Installation{}, fmt.Errorf("installation for team %s: %w", teamID, ErrNotFound)
+	}
+	return installation, nil
+}
+
+func (m *MemoryTokenStore) Save(installation Installation) error {
+	if installation.TeamID == "" {
+		return fmt.Errorf("installation is missing a team ID")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.installations[installation.TeamID] = installation
+	return nil
+}
+
+func (m *MemoryTokenStore) Delete(teamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.installations, teamID)
+	return nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Visibilities of the responses to `/oncall`.
+const (
+	// VisibilityPrivate responses are only shown to the user, the default
+	VisibilityPrivate = "private"
+	// VisibilityPublic responses are posted in the channel
+	VisibilityPublic = "public"
+)
+
+// ChannelSettings holds the defaults applied to the slash commands issued in
+// a channel. Workspace-wide defaults are stored with an empty channel ID.
+type ChannelSettings struct {
+	TeamID    string   `json:"teamId"`
+	ChannelID string   `json:"channelId,omitempty"`
+	Schedules []string `json:"schedules,omitempty"`
+	// Visibility is either VisibilityPrivate or VisibilityPublic, empty to
+	// fall back to the workspace default
+	Visibility string    `json:"visibility,omitempty"`
+	UpdatedBy  string    `json:"updatedBy"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// IsEmpty reports whether the settings hold no default, in which case they
+// can be deleted.
+func (c ChannelSettings) IsEmpty() bool {
+	return len(c.Schedules) == 0 && c.Visibility == ""
+}
+
+// SettingsStore persists channel and workspace settings.
+type SettingsStore interface {
+	GetSettings(teamID, channelID string) (ChannelSettings, error)
+	SaveSettings(settings ChannelSettings) error
+	DeleteSettings(teamID, channelID string) error
+}
+
+func settingsKey(teamID, channelID string) string {
+	return teamID + "/" + channelID
+}
+
+// MemorySettingsStore keeps settings in memory. Settings are lost on restart.
+type MemorySettingsStore struct {
+	mu       sync.RWMutex
+	settings map[string]ChannelSettings
+}
+
+func NewMemorySettingsStore() *MemorySettingsStore {
+	return &MemorySettingsStore{
+		settings: make(map[string]ChannelSettings),
+	}
+}
+
+func (m *MemorySettingsStore) GetSettings(teamID, channelID string) (ChannelSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings, ok := m.settings[settingsKey(teamID, channelID)]
+	if !ok {
+		return ChannelSettings{}, fmt.Errorf("settings for %s/%s: %w", teamID, channelID, ErrNotFound)
+	}
+	return settings, nil
+}
+
+func (m *MemorySettingsStore) SaveSettings(settings ChannelSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.settings[settingsKey(settings.TeamID, settings.ChannelID)] = settings
+	return nil
+}
+
+func (m *MemorySettingsStore) DeleteSettings(teamID, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.settings, settingsKey(teamID, channelID))
+	return nil
+}
+
+// FileSettingsStore keeps settings in a JSON file on disk.
+type FileSettingsStore struct {
+	mu       sync.RWMutex
+	path     string
+	settings map[string]ChannelSettings
+}
+
+func NewFileSettingsStore(path string) (*FileSettingsStore, error) {
+	s := &FileSettingsStore{
+		path:     path,
+		settings: make(map[string]ChannelSettings),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading settings store %s: %w", path, err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.settings); err != nil {
+			return nil, fmt.Errorf("error decoding settings store %s: %w", path, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (f *FileSettingsStore) GetSettings(teamID, channelID string) (ChannelSettings, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	settings, ok := f.settings[settingsKey(teamID, channelID)]
+	if !ok {
+		return ChannelSettings{}, fmt.Errorf("settings for %s/%s: %w", teamID, channelID, ErrNotFound)
+	}
+	return settings, nil
+}
+
+func (f *FileSettingsStore) SaveSettings(settings ChannelSettings) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := settingsKey(settings.TeamID, settings.ChannelID)
+	previous, existed := f.settings[key]
+	f.settings[key] = settings
+	if err := writeJSONFile(f.path, f.settings); err != nil {
+		if existed {
+			f.settings[key] = previous
+		} else {
+			delete(f.settings, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *FileSettingsStore) DeleteSettings(teamID, channelID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := settingsKey(teamID, channelID)
+	previous, existed := f.settings[key]
+	if !existed {
+		return nil
+	}
+	delete(f.settings, key)
+	if err := writeJSONFile(f.path, f.settings); err != nil {
+		f.settings[key] = previous
+		return err
+	}
+	return nil
+}
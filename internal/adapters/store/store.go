@@ -0,0 +1,31 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+// Installation holds the credentials obtained when the app is installed in a
+// Slack workspace through the OAuth v2 flow.
+type Installation struct {
+	TeamID       string    `json:"teamId"`
+	TeamName     string    `json:"teamName"`
+	EnterpriseID string    `json:"enterpriseId,omitempty"`
+	AppID        string    `json:"appId"`
+	BotUserID    string    `json:"botUserId"`
+	BotToken     string    `json:"botToken"`
+	Scope        string    `json:"scope"`
+	InstalledBy  string    `json:"installedBy"`
+	InstalledAt  time.Time `json:"installedAt"`
+}
+
+// TokenStore persists workspace installations keyed by Slack team ID.
+type TokenStore interface {
+	Get(teamID string) (Installation, error)
+	Save(installation Installation) error
+	Delete(teamID string) error
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	s := NewMemoryTokenStore()
+
+	if _, err := s.Get("T1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := s.Save(Installation{TeamID: "T1", BotToken: "xoxb-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	installation, err := s.Get("T1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if installation.BotToken != "xoxb-1" {
+		t.Errorf("expected bot token 'xoxb-1', got '%s'", installation.BotToken)
+	}
+
+	if err := s.Delete("T1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Get("T1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestMemoryTokenStore_MissingTeamID(t *testing.T) {
+	s := NewMemoryTokenStore()
+
+	if err := s.Save(Installation{BotToken: "xoxb-1"}); err == nil {
+		t.Error("expected error for installation without team ID, got nil")
+	}
+}
+
+func TestFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installations.json")
+
+	s, err := NewFileTokenStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Save(Installation{TeamID: "T1", BotToken: "xoxb-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Save(Installation{TeamID: "T2", BotToken: "xoxb-2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Delete("T1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected token store file to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Reopen the store to make sure the installations were persisted
+	reopened, err := NewFileTokenStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reopened.Get("T1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for deleted team, got %v", err)
+	}
+	installation, err := reopened.Get("T2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if installation.BotToken != "xoxb-2" {
+		t.Errorf("expected bot token 'xoxb-2', got '%s'", installation.BotToken)
+	}
+}
+
+func TestFileTokenStore_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installations.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := NewFileTokenStore(path); err == nil {
+		t.Error("expected error for invalid token store file, got nil")
+	}
+}
+
+func TestFileSettingsStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+
+	s, err := NewFileSettingsStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.SaveSettings(ChannelSettings{TeamID: "T1", ChannelID: "C1", Schedules: []string{"Platform"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.SaveSettings(ChannelSettings{TeamID: "T1", Schedules: []string{"Payments"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := NewFileSettingsStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	channel, err := reopened.GetSettings("T1", "C1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(channel.Schedules) != 1 || channel.Schedules[0] != "Platform" {
+		t.Errorf("expected channel schedules [Platform], got %v", channel.Schedules)
+	}
+	workspace, err := reopened.GetSettings("T1", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(workspace.Schedules) != 1 || workspace.Schedules[0] != "Payments" {
+		t.Errorf("expected workspace schedules [Payments], got %v", workspace.Schedules)
+	}
+
+	if err := reopened.DeleteSettings("T1", "C1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reopened.GetSettings("T1", "C1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
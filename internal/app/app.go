@@ -1,28 +1,99 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/metriodev/pompiers/internal/adapters/api"
 	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/metriodev/pompiers/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	defaultCallTimeout = 10 * time.Second
+	defaultConcurrency = 8
+	// timelineDays is how far ahead the schedule timelines are fetched to find
+	// when the current shifts end
+	timelineDays = 14
+)
+
+// ScheduleURL placeholders, replaced with the ID of the schedule and of the
+// team owning it
+const (
+	ScheduleIDPlaceholder = "{id}"
+	TeamIDPlaceholder     = "{teamId}"
+)
+
+// AppOption allows for functional options to configure the App
+type AppOption func(*App)
+
+// WithCallTimeout sets the deadline of every call made to the Atlassian APIs
+func WithCallTimeout(timeout time.Duration) AppOption {
+	return func(a *App) {
+		a.callTimeout = timeout
+	}
+}
+
+// WithConcurrency sets how many schedules are fetched in parallel. A value
+// lower than 1 removes the limit.
+func WithConcurrency(workers int) AppOption {
+	return func(a *App) {
+		a.concurrency = workers
+	}
+}
+
+// WithScheduleURL sets the link to the Compass page of the schedules, where
+// ScheduleIDPlaceholder and TeamIDPlaceholder are replaced. The schedules have
+// no link without it.
+func WithScheduleURL(format string) AppOption {
+	return func(a *App) {
+		a.scheduleURL = format
+	}
+}
+
 type App struct {
 	CompassClient *api.CompassClient
 	JiraClient    *api.JiraClient
+	callTimeout   time.Duration
+	concurrency   int
+	scheduleURL   string
 }
 
-func NewApp(cc *api.CompassClient, jc *api.JiraClient) *App {
-	return &App{
+func NewApp(cc *api.CompassClient, jc *api.JiraClient, opts ...AppOption) *App {
+	app := &App{
 		CompassClient: cc,
 		JiraClient:    jc,
+		callTimeout:   defaultCallTimeout,
+		concurrency:   defaultConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(app)
 	}
+
+	return app
+}
+
+// withCallTimeout derives the context of a single call to the Atlassian APIs.
+func (a *App) withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.callTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.callTimeout)
 }
 
+// AppError is an error of the Atlassian APIs carrying the HTTP status code
+// they answered with.
 type AppError struct {
 	Err      error
 	HttpCode int
@@ -32,15 +103,100 @@ func (a AppError) Error() string {
 	return a.Err.Error()
 }
 
-func (a *App) GetCurrentOnCallSchedule() (domain.CurrentOnCallSchedule, error) {
+func (a AppError) Unwrap() error {
+	return a.Err
+}
+
+// appError carries the status code of the Atlassian API error behind err, if
+// any, for the caller to tell the user why the call failed.
+func appError(err error) error {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return AppError{Err: err, HttpCode: statusErr.StatusCode}
+	}
+	return err
+}
+
+// ScheduleFilter restricts the schedules returned by GetCurrentOnCallSchedule.
+// An empty filter matches every schedule.
+type ScheduleFilter struct {
+	Names []string
+	// IDs restricts the schedules further to the Compass schedules with these
+	// IDs
+	IDs []string
+}
+
+// Matches reports whether the schedule name is part of the filter. Names are
+// compared case-insensitively.
+func (f ScheduleFilter) Matches(name string) bool {
+	if len(f.Names) == 0 {
+		return true
+	}
+	for _, n := range f.Names {
+		if strings.EqualFold(strings.TrimSpace(n), strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesID reports whether the schedule ID is part of the filter.
+func (f ScheduleFilter) MatchesID(id string) bool {
+	return len(f.IDs) == 0 || slices.Contains(f.IDs, id)
+}
+
+// GetScheduleNames returns the names of every Compass schedule.
+func (a *App) GetScheduleNames(ctx context.Context) ([]string, error) {
+	callCtx, cancel := a.withCallTimeout(ctx)
+	defer cancel()
+
+	schedules, err := a.CompassClient.GetSchedules(callCtx)
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	names := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		names = append(names, schedule.Name)
+	}
+	return names, nil
+}
+
+// CheckAtlassian verifies that the credentials are accepted by both Compass
+// and Jira.
+func (a *App) CheckAtlassian(ctx context.Context) error {
+	callCtx, cancel := a.withCallTimeout(ctx)
+	defer cancel()
+
+	if _, err := a.CompassClient.GetSchedules(callCtx); err != nil {
+		return fmt.Errorf("error checking Compass: %w", err)
+	}
+	if _, err := a.JiraClient.GetMyself(callCtx); err != nil {
+		return fmt.Errorf("error checking Jira: %w", err)
+	}
+	return nil
+}
+
+// GetCurrentOnCallSchedule fetches who is on call on every schedule matching
+// the filter. The first error cancels the calls still in flight.
+func (a *App) GetCurrentOnCallSchedule(ctx context.Context, filter ScheduleFilter) (domain.CurrentOnCallSchedule, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GetCurrentOnCallSchedule")
+	defer span.End()
+
 	// Fetch all schedules
-	schedules, err := a.CompassClient.GetSchedules()
+	callCtx, cancel := a.withCallTimeout(ctx)
+	schedules, err := a.CompassClient.GetSchedules(callCtx)
+	cancel()
 	if err != nil {
-		return domain.CurrentOnCallSchedule{}, err
+		tracing.RecordError(span, err)
+		return domain.CurrentOnCallSchedule{}, appError(err)
 	}
 
 	var mu sync.Mutex
-	g := new(errgroup.Group)
+	g, ctx := errgroup.WithContext(ctx)
+	if a.concurrency > 0 {
+		g.SetLimit(a.concurrency)
+	}
 
 	currentSchedules := make([]domain.Schedule, 0)
 
@@ -50,10 +206,27 @@ func (a *App) GetCurrentOnCallSchedule() (domain.CurrentOnCallSchedule, error) {
 
 	// Fetch OnCallParticipants for each schedule in parallel
 	for _, schedule := range schedules {
+		if !filter.MatchesID(schedule.ID) || !filter.Matches(schedule.Name) {
+			continue
+		}
 		g.Go(func() error {
-			onCallResponse, err := a.CompassClient.GetOnCallSchedules(schedule.ID)
+			ctx, span := tracing.Tracer().Start(ctx, "fetch schedule", trace.WithAttributes(
+				attribute.String("schedule.id", schedule.ID),
+				attribute.String("schedule.name", schedule.Name),
+			))
+			defer span.End()
+
+			callCtx, cancel := a.withCallTimeout(ctx)
+			onCallResponse, err := a.CompassClient.GetOnCallSchedules(callCtx, schedule.ID)
+			cancel()
 			if err != nil {
-				slog.Error(
+				tracing.RecordError(span, err)
+				if ctx.Err() != nil {
+					// Cancelled by the caller or by a failing sibling, which logged already
+					return ctx.Err()
+				}
+				slog.ErrorContext(
+					ctx,
 					"Error fetching on-call schedule",
 					"scheduleID", schedule.ID,
 					"scheduleName", schedule.Name,
@@ -64,19 +237,36 @@ func (a *App) GetCurrentOnCallSchedule() (domain.CurrentOnCallSchedule, error) {
 
 			// Filter participants with type "user"
 			var users []string
+			var responders []domain.Responder
 			for _, participant := range onCallResponse.OnCallParticipants {
 				if participant.Type == "user" {
-					userInfo, err := a.JiraClient.GetUserInfo(participant.ID)
+					callCtx, cancel := a.withCallTimeout(ctx)
+					userInfo, err := a.JiraClient.GetUserInfo(callCtx, participant.ID)
+					cancel()
 					if err != nil {
-						slog.Error("Error fetching user info", "error", err)
+						tracing.RecordError(span, err)
+						if ctx.Err() != nil {
+							return ctx.Err()
+						}
+						slog.ErrorContext(ctx, "Error fetching user info", "error", err)
 						return fmt.Errorf("error fetching user info for %s: %w", participant.ID, err)
 					}
 					users = append(users, userInfo.DisplayName)
+					responders = append(responders, domain.Responder{
+						Name:      userInfo.DisplayName,
+						AvatarURL: userInfo.AvatarUrl(),
+					})
 				}
 			}
 
-			if len(users) == 0 {
-				users = []string{"No one is on call"}
+			shiftEnd, err := a.currentShiftEnd(ctx, schedule.ID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				// The shift end is informative, the schedule is still returned
+				// without it
+				slog.WarnContext(ctx, "Error fetching schedule timeline", "scheduleID", schedule.ID, "error", err)
 			}
 
 			// Append to the response safely
@@ -84,8 +274,12 @@ func (a *App) GetCurrentOnCallSchedule() (domain.CurrentOnCallSchedule, error) {
 			currentSchedules = append(
 				currentSchedules,
 				domain.Schedule{
+					ID:          schedule.ID,
 					Name:        schedule.Name,
 					OnCallUsers: strings.Join(users, ", "),
+					Responders:  responders,
+					URL:         a.scheduleLink(schedule),
+					ShiftEnd:    shiftEnd,
 				},
 			)
 			mu.Unlock()
@@ -96,8 +290,41 @@ func (a *App) GetCurrentOnCallSchedule() (domain.CurrentOnCallSchedule, error) {
 
 	// Wait for all goroutines to complete
 	if err := g.Wait(); err != nil {
-		return domain.CurrentOnCallSchedule{}, err
+		tracing.RecordError(span, err)
+		return domain.CurrentOnCallSchedule{}, appError(err)
 	}
 
-	return domain.CurrentOnCallSchedule{Schedules: currentSchedules}, nil
+	// The schedules are fetched in parallel, they are sorted for the pages of
+	// the messages to be stable
+	slices.SortFunc(currentSchedules, func(a, b domain.Schedule) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	return domain.CurrentOnCallSchedule{Schedules: currentSchedules, UpdatedAt: time.Now()}, nil
+}
+
+// scheduleLink returns the Compass page of the schedule, empty when no link is
+// configured.
+func (a *App) scheduleLink(schedule api.Schedule) string {
+	if a.scheduleURL == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		ScheduleIDPlaceholder, url.PathEscape(schedule.ID),
+		TeamIDPlaceholder, url.PathEscape(schedule.TeamID),
+	).Replace(a.scheduleURL)
+}
+
+// currentShiftEnd returns when the current shift of the schedule ends, zero
+// when unknown.
+func (a *App) currentShiftEnd(ctx context.Context, scheduleID string) (time.Time, error) {
+	callCtx, cancel := a.withCallTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	timeline, err := a.CompassClient.GetScheduleTimeline(callCtx, scheduleID, now, timelineDays)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timeline.CurrentShiftEnd(now), nil
 }
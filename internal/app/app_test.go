@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.Run()
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// blockUntilCancelled simulates a hanging Atlassian API.
+func blockUntilCancelled(req *http.Request, cancelled chan<- struct{}) (*http.Response, error) {
+	<-req.Context().Done()
+	if cancelled != nil {
+		close(cancelled)
+	}
+	return nil, req.Context().Err()
+}
+
+func givenApp(compass, jira utils.RoundTripperFunc, opts ...AppOption) *App {
+	jiraClient, _ := api.NewJiraClient("user", "key", api.WithJiraBaseUrl("https://site.example"), api.WithJiraHttpClient(&http.Client{Transport: jira}))
+	return NewApp(
+		api.NewCompassClient("user", "key", "cloud", api.WithHttpClient(&http.Client{Transport: compass})),
+		jiraClient,
+		opts...,
+	)
+}
+
+func TestGetCurrentOnCallSchedule_CancelsSiblings(t *testing.T) {
+	cancelled := make(chan struct{})
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			switch {
+			case strings.HasSuffix(req.URL.Path, "/schedules"):
+				return jsonResponse(http.StatusOK, `{"values": [{"id": "failing", "name": "Failing"}, {"id": "hanging", "name": "Hanging"}]}`), nil
+			case strings.Contains(req.URL.Path, "/failing/"):
+				return jsonResponse(http.StatusBadRequest, `{}`), nil
+			default:
+				return blockUntilCancelled(req, cancelled)
+			}
+		},
+		func(req *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"displayName": "Test User"}`), nil
+		},
+	)
+
+	_, err := a.GetCurrentOnCallSchedule(t.Context(), ScheduleFilter{})
+	if err == nil || !strings.Contains(err.Error(), "Failing") {
+		t.Fatalf("expected the error of the failing schedule, got %v", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the hanging call to be cancelled")
+	}
+}
+
+func TestGetCurrentOnCallSchedule_CallerCancellation(t *testing.T) {
+	cancelled := make(chan struct{})
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				return jsonResponse(http.StatusOK, `{"values": [{"id": "schedule-1", "name": "Test"}]}`), nil
+			}
+			return jsonResponse(http.StatusOK, `{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`), nil
+		},
+		func(req *http.Request) (*http.Response, error) {
+			return blockUntilCancelled(req, cancelled)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := a.GetCurrentOnCallSchedule(ctx, ScheduleFilter{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the Jira call to be cancelled")
+	}
+}
+
+func TestGetCurrentOnCallSchedule_CallTimeout(t *testing.T) {
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			return blockUntilCancelled(req, nil)
+		},
+		nil,
+		WithCallTimeout(20*time.Millisecond),
+	)
+
+	_, err := a.GetCurrentOnCallSchedule(t.Context(), ScheduleFilter{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestScheduleFilter(t *testing.T) {
+	filter := ScheduleFilter{Names: []string{"Platform", " payments "}}
+
+	for name, expected := range map[string]bool{
+		"Platform": true,
+		"PAYMENTS": true,
+		"Infra":    false,
+	} {
+		if filter.Matches(name) != expected {
+			t.Errorf("expected Matches(%q) to be %v", name, expected)
+		}
+	}
+	if !(ScheduleFilter{}).Matches("anything") {
+		t.Error("expected an empty filter to match every schedule")
+	}
+
+	byID := ScheduleFilter{IDs: []string{"schedule-1"}}
+	if !byID.MatchesID("schedule-1") || byID.MatchesID("schedule-2") || !(ScheduleFilter{}).MatchesID("schedule-2") {
+		t.Error("expected the IDs to restrict the schedules")
+	}
+}
+
+func TestGetCurrentOnCallSchedule_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	schedules := make([]string, 10)
+	for i := range schedules {
+		schedules[i] = fmt.Sprintf(`{"id": "schedule-%d", "name": "Schedule %d"}`, i, i)
+	}
+
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				return jsonResponse(http.StatusOK, `{"values": [`+strings.Join(schedules, ",")+`]}`), nil
+			}
+
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return jsonResponse(http.StatusOK, `{"onCallParticipants": []}`), nil
+		},
+		nil,
+		WithConcurrency(3),
+	)
+
+	current, err := a.GetCurrentOnCallSchedule(t.Context(), ScheduleFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(current.Schedules) != 10 {
+		t.Errorf("expected 10 schedules, got %d", len(current.Schedules))
+	}
+	if maxInFlight > 3 {
+		t.Errorf("expected at most 3 schedules fetched in parallel, got %d", maxInFlight)
+	}
+}
+
+func TestGetCurrentOnCallSchedule_Responders(t *testing.T) {
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				return jsonResponse(http.StatusOK, `{"values": [
+					{"id": "s-2", "name": "platform", "teamId": "team-2"},
+					{"id": "s-1", "name": "Payments", "teamId": "team-1"}
+				]}`), nil
+			}
+			return jsonResponse(http.StatusOK, `{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`), nil
+		},
+		func(req *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"displayName": "Test User", "avatarUrls": {"48x48": "https://avatar.example/48.png"}}`), nil
+		},
+		WithScheduleURL("https://site.example/compass/teams/{teamId}/on-call?schedule={id}"),
+	)
+
+	current, err := a.GetCurrentOnCallSchedule(t.Context(), ScheduleFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(current.Schedules) != 2 || current.Schedules[0].Name != "Payments" {
+		t.Fatalf("expected the schedules sorted by name, got %+v", current.Schedules)
+	}
+
+	schedule := current.Schedules[0]
+	if schedule.URL != "https://site.example/compass/teams/team-1/on-call?schedule=s-1" {
+		t.Errorf("unexpected schedule URL %q", schedule.URL)
+	}
+	expected := domain.Responder{Name: "Test User", AvatarURL: "https://avatar.example/48.png"}
+	if len(schedule.Responders) != 1 || schedule.Responders[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, schedule.Responders)
+	}
+}
+
+func TestGetCurrentOnCallSchedule_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	a := givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				return jsonResponse(http.StatusOK, `{"values": [{"id": "platform", "name": "Platform"}, {"id": "payments", "name": "Payments"}]}`), nil
+			}
+			return jsonResponse(http.StatusOK, `{"onCallParticipants": []}`), nil
+		},
+		func(req *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"displayName": "Test User"}`), nil
+		},
+	)
+
+	if _, err := a.GetCurrentOnCallSchedule(t.Context(), ScheduleFilter{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var root sdktrace.ReadOnlySpan
+	var schedules []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "GetCurrentOnCallSchedule":
+			root = span
+		case "fetch schedule":
+			schedules = append(schedules, span)
+		}
+	}
+	if root == nil {
+		t.Fatal("expected a span for the whole fan-out")
+	}
+	if len(schedules) != 2 {
+		t.Fatalf("expected a span per schedule, got %d", len(schedules))
+	}
+	for _, span := range schedules {
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("expected the span of %v to be a child of the fan-out span", span.Attributes())
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/metriodev/pompiers/internal/metrics"
+	"github.com/metriodev/pompiers/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// boundaryDelay is waited after a shift ends before refreshing, Compass
+	// takes a moment to hand over
+	boundaryDelay = 5 * time.Second
+	// minRefreshDelay prevents a tight refresh loop when a shift end is in
+	// the past or imminent
+	minRefreshDelay = time.Second
+	// refreshTimeout bounds a refresh that is not tied to a request
+	refreshTimeout = time.Minute
+)
+
+var errNoSnapshot = errors.New("no snapshot taken yet")
+
+// Refresher keeps a snapshot of every on-call schedule in memory, so the slash
+// commands are answered without waiting for the Atlassian APIs. The snapshot
+// is rebuilt periodically and whenever a shift ends.
+//
+// A stale snapshot is still served while a refresh runs in the background.
+// Only the first request, made before any snapshot exists, waits for Compass.
+type Refresher struct {
+	app      *App
+	interval time.Duration
+
+	mu       sync.RWMutex
+	snapshot domain.CurrentOnCallSchedule
+	ready    bool
+	lastErr  error
+	baseCtx  context.Context
+
+	group singleflight.Group
+	now   func() time.Time
+}
+
+// NewRefresher creates a refresher rebuilding the snapshot every interval.
+func NewRefresher(app *App, interval time.Duration) *Refresher {
+	return &Refresher{
+		app:      app,
+		interval: interval,
+		lastErr:  errNoSnapshot,
+		baseCtx:  context.Background(),
+		now:      time.Now,
+	}
+}
+
+// Run refreshes the snapshot until the context is cancelled.
+func (r *Refresher) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.baseCtx = ctx
+	r.mu.Unlock()
+
+	for {
+		snapshot, err := r.Refresh(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Error("Error refreshing on-call snapshot", "error", err)
+		}
+
+		timer := time.NewTimer(r.nextRefresh(snapshot))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Current returns the schedules of the snapshot matching the filter. The
+// snapshot is rebuilt first when forced or when there is none yet, and in the
+// background when it is stale.
+func (r *Refresher) Current(ctx context.Context, filter ScheduleFilter, force bool) (domain.CurrentOnCallSchedule, error) {
+	snapshot, ready := r.latest()
+
+	switch {
+	case force || !ready:
+		if force {
+			metrics.ObserveSnapshotLookup("forced")
+		} else {
+			metrics.ObserveSnapshotLookup("miss")
+		}
+		refreshed, err := r.Refresh(ctx)
+		if err != nil {
+			if !ready || ctx.Err() != nil {
+				return domain.CurrentOnCallSchedule{}, err
+			}
+			slog.WarnContext(ctx, "Forced refresh failed, serving the previous snapshot", "error", err)
+		} else {
+			snapshot = refreshed
+		}
+	case r.isStale(snapshot):
+		metrics.ObserveSnapshotLookup("stale")
+		go r.refreshInBackground()
+	default:
+		metrics.ObserveSnapshotLookup("hit")
+	}
+
+	return filterSnapshot(snapshot, filter), nil
+}
+
+// Refresh rebuilds the snapshot. Concurrent calls share the same rebuild, a
+// caller giving up does not cancel it for the others.
+func (r *Refresher) Refresh(ctx context.Context) (domain.CurrentOnCallSchedule, error) {
+	r.mu.RLock()
+	baseCtx := r.baseCtx
+	r.mu.RUnlock()
+
+	result := r.group.DoChan("refresh", func() (any, error) {
+		// The refresh outlives the caller, its span is linked to the caller
+		// span instead of being a child
+		refreshCtx, span := tracing.Tracer().Start(baseCtx, "refresh snapshot", trace.WithLinks(
+			trace.LinkFromContext(ctx),
+		))
+		defer span.End()
+		refreshCtx, cancel := context.WithTimeout(refreshCtx, refreshTimeout)
+		defer cancel()
+
+		snapshot, err := r.app.GetCurrentOnCallSchedule(refreshCtx, ScheduleFilter{})
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastErr = err
+		if err != nil {
+			return nil, err
+		}
+		r.snapshot = snapshot
+		r.ready = true
+		metrics.SetSnapshotUpdatedAt(snapshot.UpdatedAt)
+		return snapshot, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return domain.CurrentOnCallSchedule{}, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return domain.CurrentOnCallSchedule{}, res.Err
+		}
+		return res.Val.(domain.CurrentOnCallSchedule), nil
+	}
+}
+
+// Err returns the error of the last refresh, nil when it succeeded.
+func (r *Refresher) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastErr
+}
+
+func (r *Refresher) refreshInBackground() {
+	if _, err := r.Refresh(context.Background()); err != nil {
+		slog.Error("Error refreshing stale on-call snapshot", "error", err)
+	}
+}
+
+func (r *Refresher) latest() (domain.CurrentOnCallSchedule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.snapshot, r.ready
+}
+
+// isStale reports whether the snapshot missed a refresh or a shift ended since
+// it was taken.
+func (r *Refresher) isStale(snapshot domain.CurrentOnCallSchedule) bool {
+	now := r.now()
+	if now.Sub(snapshot.UpdatedAt) > r.interval {
+		return true
+	}
+	next := nextShiftEnd(snapshot)
+	return !next.IsZero() && now.After(next)
+}
+
+// nextRefresh returns the delay before the next periodic refresh: the
+// interval, or less when a shift ends before.
+func (r *Refresher) nextRefresh(snapshot domain.CurrentOnCallSchedule) time.Duration {
+	delay := r.interval
+	if next := nextShiftEnd(snapshot); !next.IsZero() {
+		delay = min(delay, next.Sub(r.now())+boundaryDelay)
+	}
+	return max(delay, minRefreshDelay)
+}
+
+func nextShiftEnd(snapshot domain.CurrentOnCallSchedule) time.Time {
+	var next time.Time
+	for _, schedule := range snapshot.Schedules {
+		if schedule.ShiftEnd.IsZero() {
+			continue
+		}
+		if next.IsZero() || schedule.ShiftEnd.Before(next) {
+			next = schedule.ShiftEnd
+		}
+	}
+	return next
+}
+
+func filterSnapshot(snapshot domain.CurrentOnCallSchedule, filter ScheduleFilter) domain.CurrentOnCallSchedule {
+	filtered := domain.CurrentOnCallSchedule{
+		Schedules: make([]domain.Schedule, 0, len(snapshot.Schedules)),
+		UpdatedAt: snapshot.UpdatedAt,
+	}
+	for _, schedule := range snapshot.Schedules {
+		if filter.MatchesID(schedule.ID) && filter.Matches(schedule.Name) {
+			filtered.Schedules = append(filtered.Schedules, schedule)
+		}
+	}
+	return filtered
+}
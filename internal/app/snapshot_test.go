@@ -0,0 +1,123 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/domain"
+)
+
+// givenCountingApp returns an app with two schedules and counts how many times
+// the on-call participants are fetched.
+func givenCountingApp(fetches *atomic.Int32) *App {
+	return givenApp(
+		func(req *http.Request) (*http.Response, error) {
+			switch {
+			case strings.HasSuffix(req.URL.Path, "/schedules"):
+				return jsonResponse(http.StatusOK, `{"values": [{"id": "platform", "name": "Platform"}, {"id": "payments", "name": "Payments"}]}`), nil
+			case strings.HasSuffix(req.URL.Path, "/on-calls"):
+				fetches.Add(1)
+				return jsonResponse(http.StatusOK, `{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`), nil
+			default:
+				return jsonResponse(http.StatusOK, `{}`), nil
+			}
+		},
+		func(req *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"displayName": "Test User"}`), nil
+		},
+	)
+}
+
+func TestRefresher_ServesSnapshot(t *testing.T) {
+	var fetches atomic.Int32
+	r := NewRefresher(givenCountingApp(&fetches), time.Minute)
+
+	first, err := r.Current(t.Context(), ScheduleFilter{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first.Schedules) != 2 || first.UpdatedAt.IsZero() {
+		t.Fatalf("expected a snapshot of both schedules, got %+v", first)
+	}
+
+	filtered, err := r.Current(t.Context(), ScheduleFilter{Names: []string{"payments"}}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered.Schedules) != 1 || filtered.Schedules[0].Name != "Payments" {
+		t.Errorf("expected only the Payments schedule, got %+v", filtered.Schedules)
+	}
+	if !filtered.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("expected the same snapshot, got %v and %v", first.UpdatedAt, filtered.UpdatedAt)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected the schedules to be fetched once, got %d calls", n)
+	}
+
+	if _, err := r.Current(t.Context(), ScheduleFilter{}, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := fetches.Load(); n != 4 {
+		t.Errorf("expected a forced refresh to fetch the schedules again, got %d calls", n)
+	}
+}
+
+func TestRefresher_StaleWhileRevalidate(t *testing.T) {
+	var fetches atomic.Int32
+	r := NewRefresher(givenCountingApp(&fetches), time.Minute)
+
+	first, err := r.Current(t.Context(), ScheduleFilter{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	stale, err := r.Current(t.Context(), ScheduleFilter{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stale.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Error("expected the stale snapshot to be served")
+	}
+
+	deadline := time.After(time.Second)
+	for fetches.Load() < 4 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected a background refresh, got %d calls", fetches.Load())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestRefresher_NextRefresh(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRefresher(nil, time.Hour)
+	r.now = func() time.Time { return now }
+
+	snapshot := domain.CurrentOnCallSchedule{
+		UpdatedAt: now,
+		Schedules: []domain.Schedule{
+			{Name: "Platform", ShiftEnd: now.Add(10 * time.Minute)},
+			{Name: "Payments"},
+		},
+	}
+	if delay := r.nextRefresh(snapshot); delay != 10*time.Minute+boundaryDelay {
+		t.Errorf("expected a refresh after the shift end, got %v", delay)
+	}
+	if r.isStale(snapshot) {
+		t.Error("expected a fresh snapshot")
+	}
+
+	now = now.Add(11 * time.Minute)
+	if !r.isStale(snapshot) {
+		t.Error("expected the snapshot to be stale once a shift ended")
+	}
+	if delay := r.nextRefresh(snapshot); delay != minRefreshDelay {
+		t.Errorf("expected the minimum delay, got %v", delay)
+	}
+}
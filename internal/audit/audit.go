@@ -0,0 +1,187 @@
+// Package audit records the actions performed by the bot, who asked for them
+// and how they went, so they can be looked up for compliance.
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultRecentEntries is how many entries are kept in memory to answer the
+// queries.
+const DefaultRecentEntries = 1000
+
+// Entry is an action performed on behalf of a Slack user.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	TeamID    string    `json:"team_id"`
+	ChannelID string    `json:"channel_id,omitempty"`
+	UserID    string    `json:"user_id"`
+	// Command is the slash command, e.g. "/oncall"
+	Command string `json:"command"`
+	// Action is the subcommand, e.g. "config"
+	Action string `json:"action"`
+	Args   string `json:"args,omitempty"`
+	// Schedules are the schedules shown or changed
+	Schedules []string `json:"schedules,omitempty"`
+	// Result is ok, error, denied or not_installed
+	Result string `json:"result"`
+	// Error is the error returned by the Atlassian APIs, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Sink writes the entries somewhere they are kept.
+type Sink interface {
+	Write(ctx context.Context, entry Entry) error
+}
+
+// Query selects the recent entries of a workspace.
+type Query struct {
+	TeamID string
+	// UserID and Action are ignored when empty
+	UserID string
+	Action string
+	Limit  int
+}
+
+func (q Query) matches(entry Entry) bool {
+	return entry.TeamID == q.TeamID &&
+		(q.UserID == "" || entry.UserID == q.UserID) &&
+		(q.Action == "" || entry.Action == q.Action)
+}
+
+// Option allows for functional options to configure the Log
+type Option func(*Log)
+
+// WithSink writes the entries to the sink, on top of the other ones.
+func WithSink(sink Sink) Option {
+	return func(l *Log) {
+		l.sinks = append(l.sinks, sink)
+	}
+}
+
+// WithRecentEntries sets how many entries are kept in memory for the queries.
+func WithRecentEntries(n int) Option {
+	return func(l *Log) {
+		l.size = n
+	}
+}
+
+// WithHistory loads the entries recorded before the start, e.g. read back with
+// ReadFile, so they can be queried.
+func WithHistory(entries []Entry) Option {
+	return func(l *Log) {
+		l.history = entries
+	}
+}
+
+// Log is the append-only audit log. Entries are written to every sink and the
+// most recent ones are kept in memory to be queried.
+type Log struct {
+	sinks   []Sink
+	size    int
+	history []Entry
+
+	mu     sync.Mutex
+	recent []Entry
+	next   int
+	now    func() time.Time
+}
+
+func NewLog(opts ...Option) *Log {
+	l := &Log{
+		size: DefaultRecentEntries,
+		now:  time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	for _, entry := range l.history {
+		l.remember(entry)
+	}
+	l.history = nil
+
+	return l
+}
+
+// Record timestamps the entry and writes it to the sinks. A failing sink does
+// not prevent the action, its error is logged.
+func (l *Log) Record(ctx context.Context, entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = l.now().UTC()
+	}
+
+	l.mu.Lock()
+	l.remember(entry)
+	l.mu.Unlock()
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, entry); err != nil {
+			slog.ErrorContext(ctx, "Error writing audit entry", "sink", sinkName(sink), "error", err)
+		}
+	}
+}
+
+// remember adds the entry to the ring of recent entries.
+func (l *Log) remember(entry Entry) {
+	if l.size <= 0 {
+		return
+	}
+	if len(l.recent) < l.size {
+		l.recent = append(l.recent, entry)
+		return
+	}
+	l.recent[l.next] = entry
+	l.next = (l.next + 1) % l.size
+}
+
+// Recent returns the latest entries matching the query, newest first.
+func (l *Log) Recent(query Query) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []Entry
+	for i := range l.recent {
+		// Walk the ring backwards from the newest entry
+		entry := l.recent[(l.next-1-i+2*len(l.recent))%len(l.recent)]
+		if !query.matches(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if query.Limit > 0 && len(entries) == query.Limit {
+			break
+		}
+	}
+	return entries
+}
+
+// Close flushes and closes the sinks that need it.
+func (l *Log) Close() error {
+	var firstErr error
+	for _, sink := range l.sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func sinkName(sink Sink) string {
+	switch sink.(type) {
+	case *FileSink:
+		return "file"
+	case *WriterSink:
+		return "writer"
+	case *WebhookSink:
+		return "webhook"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.Run()
+}
+
+func TestLog_Recent(t *testing.T) {
+	log := NewLog(WithRecentEntries(3))
+	for i := range 5 {
+		log.Record(t.Context(), Entry{TeamID: "T1", UserID: "U" + strconv.Itoa(i%2), Action: "oncall", Args: strconv.Itoa(i)})
+	}
+	log.Record(t.Context(), Entry{TeamID: "T2", UserID: "U0", Action: "config"})
+
+	entries := log.Recent(Query{TeamID: "T1"})
+	if len(entries) != 2 || entries[0].Args != "4" || entries[1].Args != "3" {
+		t.Errorf("expected the 2 entries of T1 left in the ring, newest first, got %+v", entries)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("expected the entries to be timestamped")
+	}
+
+	entries = log.Recent(Query{TeamID: "T1", UserID: "U1"})
+	if len(entries) != 1 || entries[0].Args != "3" {
+		t.Errorf("expected the entry of U1, got %+v", entries)
+	}
+
+	entries = log.Recent(Query{TeamID: "T2", Action: "oncall"})
+	if len(entries) != 0 {
+		t.Errorf("expected no entry, got %+v", entries)
+	}
+}
+
+func TestFileSink_ReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log := NewLog(WithSink(sink))
+	for i := range 3 {
+		log.Record(t.Context(), Entry{TeamID: "T1", Args: strconv.Itoa(i)})
+	}
+	log.Close()
+
+	// A line cut by a crash is skipped
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	file.WriteString(`{"team_id":"T1","ar`)
+	file.Close()
+
+	history, err := ReadFile(path, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 2 || history[0].Args != "1" || history[1].Args != "2" {
+		t.Fatalf("expected the last 2 entries, got %+v", history)
+	}
+
+	entries := NewLog(WithHistory(history)).Recent(Query{TeamID: "T1"})
+	if len(entries) != 2 || entries[0].Args != "2" {
+		t.Errorf("expected the history to be queried, got %+v", entries)
+	}
+}
+
+func TestReadFile_Missing(t *testing.T) {
+	history, err := ReadFile(filepath.Join(t.TempDir(), "missing.jsonl"), 10)
+	if err != nil || history != nil {
+		t.Errorf("expected no entry and no error, got %v, %v", history, err)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewLog(WithSink(NewWriterSink(&buf)))
+	log.Record(t.Context(), Entry{TeamID: "T1", UserID: "U1", Command: "/oncall", Action: "config", Schedules: []string{"Platform"}, Result: "ok"})
+
+	var entry Entry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry.UserID != "U1" || entry.Schedules[0] != "Platform" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan Entry, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry Entry
+		json.NewDecoder(r.Body).Decode(&entry)
+		received <- entry
+	}))
+	defer webhook.Close()
+
+	log := NewLog(WithSink(NewWebhookSink(webhook.URL, webhook.Client())))
+	log.Record(t.Context(), Entry{TeamID: "T1", Action: "refresh"})
+	log.Close()
+
+	select {
+	case entry := <-received:
+		if entry.Action != "refresh" {
+			t.Errorf("unexpected entry: %+v", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the entry to be posted")
+	}
+}
+
+func TestWebhookSink_WriteAfterClose(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no entry to be posted")
+	}))
+	defer webhook.Close()
+
+	sink := NewWebhookSink(webhook.URL, webhook.Client())
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.Write(t.Context(), Entry{TeamID: "T1", Action: "refresh"}); err == nil {
+		t.Error("expected the entry written after Close to be dropped")
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("expected Close to be idempotent, got %v", err)
+	}
+}
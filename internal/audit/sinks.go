@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	webhookQueueSize = 100
+	webhookTimeout   = 5 * time.Second
+)
+
+// WriterSink writes the entries as JSON lines, e.g. to stdout.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(_ context.Context, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit entry: %w", err)
+	}
+	return nil
+}
+
+// FileSink appends the entries as JSON lines to a file, which is never
+// rewritten.
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit file: %w", err)
+	}
+	return &FileSink{WriterSink: NewWriterSink(file), file: file}, nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// ReadFile returns the last n entries of a file written by a FileSink, or
+// none when the file does not exist yet. Lines that can't be decoded, e.g. one
+// cut by a crash, are skipped.
+func ReadFile(path string, n int) ([]Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit file: %w", err)
+	}
+	return entries, nil
+}
+
+// WebhookSink posts every entry as JSON to a URL. The entries are sent in the
+// background so a slow endpoint doesn't delay the commands; they are dropped
+// when too many are pending, or once the sink is closed.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	done   chan struct{}
+
+	// mu guards entries against a Write racing with Close
+	mu      sync.Mutex
+	closed  bool
+	entries chan Entry
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	s := &WebhookSink{
+		url:     url,
+		client:  client,
+		entries: make(chan Entry, webhookQueueSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *WebhookSink) Write(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("webhook sink closed, entry dropped")
+	}
+	select {
+	case s.entries <- entry:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue full, entry dropped")
+	}
+}
+
+// Close sends the pending entries and stops the sink. The entries written
+// afterwards are dropped.
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.entries)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for entry := range s.entries {
+		if err := s.post(entry); err != nil {
+			slog.Error("Error sending audit entry to the webhook", "error", err)
+		}
+	}
+}
+
+func (s *WebhookSink) post(entry Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequestWithContext: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/policy"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ${VAR} or ${VAR:-default}. A literal "${" is written "$${".
+	envPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+)
+
+// File is the content of the YAML configuration file. Top-level keys that
+// are not one of the sections below are treated as command line flags, e.g.
+// `atlassian-api-key: ${ATLASSIAN_API_KEY}`.
+type File struct {
+	// Aliases maps a name usable in place of schedule names to the schedules
+	// it stands for.
+	Aliases map[string][]string `yaml:"aliases"`
+	// Channels holds the default schedules of channels and workspaces.
+	Channels []ChannelMapping `yaml:"channels"`
+	// Policies restrict the privileged subcommands to some users, usergroups
+	// or channels.
+	Policies []PolicyRule `yaml:"policies"`
+
+	Flags map[string]any `yaml:",inline"`
+}
+
+// ChannelMapping sets the schedules `/oncall` shows by default in a channel,
+// and whether it answers publicly. Without channel, the mapping applies to
+// every channel of the workspace.
+type ChannelMapping struct {
+	Team       string   `yaml:"team"`
+	Channel    string   `yaml:"channel"`
+	Schedules  []string `yaml:"schedules"`
+	Visibility string   `yaml:"visibility"`
+}
+
+// PolicyRule allows a subcommand to the listed users, members of the listed
+// usergroups and, with admins, to the workspace admins. The rule only applies
+// in the listed channels, if any, and to the team, if set.
+type PolicyRule struct {
+	Action     string   `yaml:"action"`
+	Team       string   `yaml:"team"`
+	Channels   []string `yaml:"channels"`
+	Users      []string `yaml:"users"`
+	Usergroups []string `yaml:"usergroups"`
+	Admins     bool     `yaml:"admins"`
+}
+
+// Load reads, interpolates and validates the configuration file at path.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %w", err)
+	}
+	defer f.Close()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Parse reads and validates a configuration file.
+func Parse(r io.Reader) (*File, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+
+	file := &File{}
+	if root.Kind == 0 {
+		// Empty file
+		return file, nil
+	}
+	if err := interpolate(&root); err != nil {
+		return nil, err
+	}
+	if err := root.Decode(file); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// interpolate replaces the ${VAR} references in the values of the parsed file
+// with the environment variables, so secrets don't have to be written in the
+// file. Comments and keys are left alone, and the values are substituted as a
+// whole, whatever characters the variables hold.
+func interpolate(root *yaml.Node) error {
+	var missing []string
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for i, child := range node.Content {
+			if node.Kind == yaml.MappingNode && i%2 == 0 {
+				// Keys
+				continue
+			}
+			walk(child)
+		}
+		if node.Kind != yaml.ScalarNode {
+			return
+		}
+
+		value := envPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			groups := envPattern.FindStringSubmatch(match)
+			name, hasDefault := groups[1], groups[2] != ""
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+			if hasDefault {
+				return groups[3]
+			}
+			missing = append(missing, name)
+			return match
+		})
+		if value != node.Value {
+			node.Value = value
+			if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				// Plain values are typed after the substitution, e.g. a port
+				node.Tag = ""
+			}
+		}
+	}
+	walk(root)
+
+	if len(missing) > 0 {
+		return fmt.Errorf("undefined environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Validate reports every problem found in the structured sections.
+func (f *File) Validate() error {
+	var errs []error
+
+	for alias, schedules := range f.Aliases {
+		if strings.TrimSpace(alias) == "" {
+			errs = append(errs, fmt.Errorf("aliases: alias names can't be empty"))
+		}
+		if len(schedules) == 0 {
+			errs = append(errs, fmt.Errorf("aliases.%s: at least one schedule is required", alias))
+		}
+	}
+
+	seen := make(map[string]int)
+	for i, mapping := range f.Channels {
+		if mapping.Team == "" {
+			errs = append(errs, fmt.Errorf("channels[%d]: team is required", i))
+		}
+		if len(mapping.Schedules) == 0 && mapping.Visibility == "" {
+			errs = append(errs, fmt.Errorf("channels[%d]: at least one schedule or a visibility is required", i))
+		}
+		switch mapping.Visibility {
+		case "", store.VisibilityPrivate, store.VisibilityPublic:
+		default:
+			errs = append(errs, fmt.Errorf("channels[%d]: unknown visibility %q, expected %q or %q", i, mapping.Visibility, store.VisibilityPrivate, store.VisibilityPublic))
+		}
+		key := mapping.Team + "/" + mapping.Channel
+		if previous, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("channels[%d]: duplicates channels[%d]", i, previous))
+		}
+		seen[key] = i
+	}
+
+	for i, rule := range f.Policies {
+		if rule.Action != policy.AnyAction && !slices.Contains(policy.Actions, rule.Action) {
+			errs = append(errs, fmt.Errorf("policies[%d]: unknown action %q, expected one of %s or %q", i, rule.Action, strings.Join(policy.Actions, ", "), policy.AnyAction))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Loader is a kong.ConfigurationLoader resolving the command line flags from
+// the top-level keys of the configuration file.
+func Loader(r io.Reader) (kong.Resolver, error) {
+	file, err := Parse(r)
+	if err != nil {
+		if f, ok := r.(interface{ Name() string }); ok {
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		return nil, err
+	}
+	return resolver{flags: file.Flags}, nil
+}
+
+type resolver struct {
+	flags map[string]any
+}
+
+func (r resolver) Validate(app *kong.Application) error {
+	known := make(map[string]bool)
+	for _, node := range app.Leaves(false) {
+		for _, flag := range node.Flags {
+			known[flag.Name] = true
+		}
+	}
+	for _, flag := range app.Flags {
+		known[flag.Name] = true
+	}
+
+	var unknown []string
+	for key := range r.flags {
+		if !known[strings.ReplaceAll(key, "_", "-")] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown configuration keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Resolve returns the value of the flag from the file. Flags set through an
+// environment variable are skipped so the environment overrides the file.
+func (r resolver) Resolve(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+	for _, env := range flag.Envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return nil, nil
+		}
+	}
+
+	for _, key := range []string{flag.Name, strings.ReplaceAll(flag.Name, "-", "_")} {
+		if value, ok := r.flags[key]; ok {
+			return value, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+const mockConfig = `
+port: 9090
+atlassian-api-key: ${POMPIERS_TEST_KEY}
+slack_signing_secret: ${POMPIERS_TEST_MISSING:-fallback}
+admins: [U1, U2]
+aliases:
+  sre: [Platform, Infrastructure]
+channels:
+  - team: T1
+    channel: C1
+    schedules: [sre]
+  - team: T1
+    schedules: [Payments]
+policies:
+  - action: config
+    channels: [C1]
+    usergroups: ["@sre"]
+`
+
+type mockCli struct {
+	Config             kong.ConfigFlag
+	Port               int
+	AtlassianApiKey    string
+	SlackSigningSecret string
+	Admins             []string
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("POMPIERS_TEST_KEY", "secret-key")
+	path := writeConfig(t, mockConfig)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(file.Aliases["sre"]) != 2 {
+		t.Errorf("expected 2 schedules for alias 'sre', got %v", file.Aliases["sre"])
+	}
+	if len(file.Channels) != 2 || file.Channels[0].Channel != "C1" {
+		t.Errorf("unexpected channel mappings: %+v", file.Channels)
+	}
+	if len(file.Policies) != 1 || file.Policies[0].Usergroups[0] != "@sre" {
+		t.Errorf("unexpected policies: %+v", file.Policies)
+	}
+	if file.Flags["atlassian-api-key"] != "secret-key" {
+		t.Errorf("expected interpolated API key, got %v", file.Flags["atlassian-api-key"])
+	}
+	if file.Flags["slack_signing_secret"] != "fallback" {
+		t.Errorf("expected default value, got %v", file.Flags["slack_signing_secret"])
+	}
+}
+
+func TestLoad_UndefinedVariable(t *testing.T) {
+	path := writeConfig(t, "atlassian-api-key: ${POMPIERS_TEST_UNDEFINED}\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "undefined environment variables: POMPIERS_TEST_UNDEFINED") {
+		t.Errorf("expected undefined variable error, got %v", err)
+	}
+}
+
+func TestLoad_EscapedVariable(t *testing.T) {
+	path := writeConfig(t, "atlassian-api-key: $${NOT_A_VARIABLE}\n")
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.Flags["atlassian-api-key"] != "${NOT_A_VARIABLE}" {
+		t.Errorf("expected escaped value to be kept, got %v", file.Flags["atlassian-api-key"])
+	}
+}
+
+func TestLoad_CommentsNotInterpolated(t *testing.T) {
+	path := writeConfig(t, "# ${VAR} or ${VAR:-default} is replaced\nport: 9090 # ${POMPIERS_TEST_UNDEFINED}\n")
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected the comments to be left alone, got %v", err)
+	}
+	if file.Flags["port"] != 9090 {
+		t.Errorf("expected the port, got %v", file.Flags["port"])
+	}
+}
+
+func TestLoad_InterpolatedValueKeptWhole(t *testing.T) {
+	t.Setenv("POMPIERS_TEST_KEY", "abc #def: 'x'")
+	t.Setenv("POMPIERS_TEST_PORT", "9090")
+	path := writeConfig(t, "atlassian-api-key: ${POMPIERS_TEST_KEY}\nport: ${POMPIERS_TEST_PORT}\n")
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file.Flags["atlassian-api-key"] != "abc #def: 'x'" {
+		t.Errorf("expected the whole secret, got %q", file.Flags["atlassian-api-key"])
+	}
+	if file.Flags["port"] != 9090 {
+		t.Errorf("expected a plain value to be typed after the substitution, got %#v", file.Flags["port"])
+	}
+}
+
+func TestLoad_Example(t *testing.T) {
+	for _, name := range []string{"ATLASSIAN_API_USER", "ATLASSIAN_API_KEY", "ATLASSIAN_CLOUD_ID", "ATLASSIAN_SITE_URL", "SLACK_SIGNING_SECRET", "STATUS_PAGE_API_KEY"} {
+		t.Setenv(name, "value")
+	}
+
+	if _, err := Load(filepath.Join("..", "..", "config.example.yaml")); err != nil {
+		t.Errorf("expected the example to load, got %v", err)
+	}
+}
+
+func TestLoad_ValidationErrors(t *testing.T) {
+	path := writeConfig(t, `
+aliases:
+  sre: []
+channels:
+  - channel: C1
+    schedules: [Platform]
+  - team: T1
+  - team: T1
+    schedules: [Platform]
+  - team: T2
+    visibility: everyone
+policies:
+  - action: config
+    usergroups: ["@sre"]
+  - action: override
+`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	for _, expected := range []string{
+		"aliases.sre: at least one schedule is required",
+		"channels[0]: team is required",
+		"channels[1]: at least one schedule or a visibility is required",
+		"channels[2]: duplicates channels[1]",
+		`channels[3]: unknown visibility "everyone"`,
+		`policies[1]: unknown action "override"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain '%s', got: %v", expected, err)
+		}
+	}
+}
+
+func TestLoader_ResolvesFlags(t *testing.T) {
+	t.Setenv("POMPIERS_TEST_KEY", "secret-key")
+	t.Setenv("PORT", "7070")
+	path := writeConfig(t, mockConfig)
+
+	var cli mockCli
+	parser, err := kong.New(&cli, kong.DefaultEnvars(""), kong.Configuration(Loader))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := parser.Parse([]string{"--config", path, "--admins", "U3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cli.AtlassianApiKey != "secret-key" {
+		t.Errorf("expected API key from the file, got '%s'", cli.AtlassianApiKey)
+	}
+	if cli.SlackSigningSecret != "fallback" {
+		t.Errorf("expected signing secret from the file, got '%s'", cli.SlackSigningSecret)
+	}
+	if cli.Port != 7070 {
+		t.Errorf("expected the environment to override the file, got port %d", cli.Port)
+	}
+	if len(cli.Admins) != 1 || cli.Admins[0] != "U3" {
+		t.Errorf("expected the command line to override the file, got %v", cli.Admins)
+	}
+}
+
+func TestLoader_UnknownKeys(t *testing.T) {
+	path := writeConfig(t, "prot: 8080\n")
+
+	var cli mockCli
+	parser, err := kong.New(&cli, kong.Configuration(Loader))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = parser.Parse([]string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "unknown configuration keys: prot") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
@@ -1,10 +1,30 @@
 package domain
 
+import "time"
+
 type CurrentOnCallSchedule struct {
 	Schedules []Schedule
+	// UpdatedAt is when the schedules were fetched from Compass
+	UpdatedAt time.Time
 }
 
 type Schedule struct {
-	Name        string
+	// ID is the ID of the schedule in Compass
+	ID   string
+	Name string
+	// OnCallUsers lists the names of the responders, empty when no one is on
+	// call. The messages tell it in the language of the user.
 	OnCallUsers string
+	// Responders are the users on call, empty when no one is
+	Responders []Responder
+	// URL is the page of the schedule in Compass, empty when unknown
+	URL string
+	// ShiftEnd is when the current shift ends, zero when unknown
+	ShiftEnd time.Time
+}
+
+type Responder struct {
+	Name string
+	// AvatarURL is the Jira avatar of the user, empty when unknown
+	AvatarURL string
 }
@@ -0,0 +1,162 @@
+// Package logging configures the slog handlers of the service: the output
+// format, the attributes scoped to a request and the redaction of secrets and
+// personal data.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attributes, header names and form fields whose value
+// is never logged. Keys are compared case-insensitively, dashes and
+// underscores ignored.
+var sensitiveKeys = map[string]bool{
+	"token":           true,
+	"bottoken":        true,
+	"accesstoken":     true,
+	"apptoken":        true,
+	"apikey":          true,
+	"secret":          true,
+	"clientsecret":    true,
+	"signingsecret":   true,
+	"password":        true,
+	"authorization":   true,
+	"cookie":          true,
+	"setcookie":       true,
+	"signature":       true,
+	"xslacksignature": true,
+	"responseurl":     true,
+	"triggerid":       true,
+	"username":        true,
+	"email":           true,
+	"displayname":     true,
+	"body":            true,
+	"payload":         true,
+	"oncallusers":     true,
+}
+
+// sensitiveValues match secrets wherever they appear in a logged string, e.g.
+// in an error message echoing a request.
+var sensitiveValues = regexp.MustCompile(
+	`xox[abposre]-[A-Za-z0-9-]+` + // Slack bot, user and refresh tokens
+		`|xapp-[A-Za-z0-9-]+` + // Slack app-level tokens
+		`|v0=[a-f0-9]{64}` + // Slack request signatures
+		`|(?i:bearer|basic) [A-Za-z0-9._~+/=-]+` + // Authorization header values
+		`|https://hooks\.slack\.com/[^\s"']+` + // Response URLs
+		`|[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, // Email addresses
+)
+
+// NewHandler returns a handler writing text or JSON records, with the
+// sensitive attributes redacted and the request attributes added.
+func NewHandler(format string, w io.Writer, level slog.Leveler) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: Redact}
+
+	var handler slog.Handler
+	switch format {
+	case "text", "":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return NewContextHandler(handler), nil
+}
+
+// Redact replaces the value of the sensitive attributes, and the secrets found
+// in the other string values. It is meant as slog.HandlerOptions.ReplaceAttr.
+func Redact(_ []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactString(value.String()))
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactString(v.Error()))
+		case http.Header:
+			return slog.Any(a.Key, http.Header(redactMap(v)))
+		case url.Values:
+			return slog.Any(a.Key, url.Values(redactMap(v)))
+		case map[string][]string:
+			return slog.Any(a.Key, redactMap(v))
+		case fmt.Stringer:
+			return slog.String(a.Key, redactString(v.String()))
+		}
+	}
+	return a
+}
+
+func isSensitive(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	return sensitiveKeys[normalized]
+}
+
+func redactString(s string) string {
+	return sensitiveValues.ReplaceAllString(s, redacted)
+}
+
+func redactMap(m map[string][]string) map[string][]string {
+	clean := make(map[string][]string, len(m))
+	for key, values := range m {
+		if isSensitive(key) {
+			clean[key] = []string{redacted}
+			continue
+		}
+		cleanValues := make([]string, len(values))
+		for i, value := range values {
+			cleanValues[i] = redactString(value)
+		}
+		clean[key] = cleanValues
+	}
+	return clean
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose records, logged with slog.InfoContext and
+// the like, carry the given attributes on top of the ones already in ctx.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// ContextHandler adds the attributes stored with WithAttrs to the records.
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: next}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,156 @@
+// Package metrics exposes the Prometheus metrics of the service.
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pompiers"
+
+var (
+	// Registry holds every metric of the service, on top of the Go runtime
+	// and process metrics.
+	Registry = prometheus.NewRegistry()
+
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_commands_total",
+		Help:      "Slash commands handled, by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	slackRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "slack_request_duration_seconds",
+		Help:      "Time taken to answer the requests sent by Slack, by handler.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2, 3, 5, 10},
+	}, []string{"handler"})
+
+	atlassianRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "atlassian_request_duration_seconds",
+		Help:      "Duration of the requests made to the Atlassian APIs, by endpoint and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	apiRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Requests to the REST API, by endpoint and status code.",
+	}, []string{"endpoint", "status"})
+
+	snapshotLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "snapshot_lookups_total",
+		Help:      "Reads of the on-call snapshot, by result: hit, stale, miss or forced.",
+	}, []string{"result"})
+
+	// snapshotUpdatedAt is the unix time of the last snapshot, in nanoseconds
+	snapshotUpdatedAt atomic.Int64
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		commandsTotal,
+		slackRequestDuration,
+		atlassianRequestDuration,
+		apiRequestsTotal,
+		snapshotLookups,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "snapshot_age_seconds",
+			Help:      "Age of the on-call snapshot served to the slash commands, NaN until the first one is taken.",
+		}, snapshotAge),
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveCommand counts a slash command.
+func ObserveCommand(command, outcome string) {
+	commandsTotal.WithLabelValues(command, outcome).Inc()
+}
+
+// ObserveSlackRequest records the time taken by a Slack handler.
+func ObserveSlackRequest(handler string, duration time.Duration) {
+	slackRequestDuration.WithLabelValues(handler).Observe(duration.Seconds())
+}
+
+// ObserveAPIRequest counts a request to the REST API.
+func ObserveAPIRequest(endpoint string, status int) {
+	apiRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+}
+
+// ObserveSnapshotLookup counts a read of the on-call snapshot.
+func ObserveSnapshotLookup(result string) {
+	snapshotLookups.WithLabelValues(result).Inc()
+}
+
+// SetSnapshotUpdatedAt records when the snapshot served to the slash commands
+// was taken.
+func SetSnapshotUpdatedAt(t time.Time) {
+	snapshotUpdatedAt.Store(t.UnixNano())
+}
+
+func snapshotAge() float64 {
+	updatedAt := snapshotUpdatedAt.Load()
+	if updatedAt == 0 {
+		return math.NaN()
+	}
+	return time.Since(time.Unix(0, updatedAt)).Seconds()
+}
+
+// InstrumentTransport records the duration and status of every request sent
+// through the transport. Requests failing without a response are recorded
+// with the "error" status.
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next}
+}
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	atlassianRequestDuration.WithLabelValues(Endpoint(req.URL.Path), status).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
+
+// Endpoint turns the path of an Atlassian request into a label of bounded
+// cardinality, replacing the cloud and schedule IDs with placeholders.
+func Endpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		switch segments[i-1] {
+		case "cloud":
+			segments[i] = "{cloudId}"
+		case "schedules":
+			segments[i] = "{scheduleId}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+)
+
+// scrape returns the metrics as exposed to Prometheus.
+func scrape(t *testing.T) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v", rr.Code)
+	}
+	return rr.Body.String()
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/compass/cloud/abc-123/ops/v1/schedules", "/compass/cloud/{cloudId}/ops/v1/schedules"},
+		{"/compass/cloud/abc-123/ops/v1/schedules/s-1/on-calls", "/compass/cloud/{cloudId}/ops/v1/schedules/{scheduleId}/on-calls"},
+		{"/compass/cloud/abc-123/ops/v1/schedules/s-1/timeline", "/compass/cloud/{cloudId}/ops/v1/schedules/{scheduleId}/timeline"},
+		{"/rest/api/3/user", "/rest/api/3/user"},
+	}
+
+	for _, tt := range tests {
+		if got := Endpoint(tt.path); got != tt.expected {
+			t.Errorf("Endpoint(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	client := &http.Client{
+		Transport: InstrumentTransport(utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})),
+	}
+
+	res, err := client.Get("https://api.atlassian.com/compass/cloud/abc-123/ops/v1/schedules/s-1/on-calls")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+
+	expected := `pompiers_atlassian_request_duration_seconds_count{endpoint="/compass/cloud/{cloudId}/ops/v1/schedules/{scheduleId}/on-calls",status="429"} 1`
+	if body := scrape(t); !strings.Contains(body, expected) {
+		t.Errorf("expected %s in:\n%s", expected, body)
+	}
+}
+
+func TestSnapshotAge(t *testing.T) {
+	if body := scrape(t); !strings.Contains(body, "pompiers_snapshot_age_seconds NaN") {
+		t.Errorf("expected no snapshot age before the first snapshot, got:\n%s", body)
+	}
+
+	SetSnapshotUpdatedAt(time.Now().Add(-time.Minute))
+	if age := snapshotAge(); age < 60 || age > 61 {
+		t.Errorf("expected a snapshot age of a minute, got %v", age)
+	}
+}
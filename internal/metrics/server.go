@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+)
+
+const path = "/metrics"
+
+// ServerOption allows for functional options to configure the Server
+type ServerOption func(*Server)
+
+// WithHandler serves another endpoint on the metrics listener.
+func WithHandler(pattern string, handler http.Handler) ServerOption {
+	return func(s *Server) {
+		s.handlers[pattern] = handler
+	}
+}
+
+// Server exposes the metrics on a listener of its own, so they are neither
+// behind the Slack signature verification nor reachable through the public
+// Slack endpoint.
+type Server struct {
+	host       string
+	port       int
+	handlers   map[string]http.Handler
+	httpserver *http.Server
+}
+
+func NewServer(host string, port int, opts ...ServerOption) *Server {
+	s := &Server{
+		host:     host,
+		port:     port,
+		handlers: map[string]http.Handler{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Server) Start() error {
+	mux := http.NewServeMux()
+	mux.Handle(path, Handler())
+	for pattern, handler := range s.handlers {
+		mux.Handle(pattern, handler)
+	}
+	s.httpserver = &http.Server{Handler: mux}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.host, s.port))
+	if err != nil {
+		return fmt.Errorf("net.Listen: %v", err)
+	}
+	go func(l net.Listener) {
+		slog.Info(fmt.Sprintf("Metrics server started on %s", l.Addr().String()))
+		if err := s.httpserver.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error starting metrics server", "error", err)
+			os.Exit(1)
+		}
+	}(listener)
+
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.httpserver.Shutdown(ctx)
+}
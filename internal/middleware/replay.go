@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// nonceCache remembers the signatures of the requests accepted recently, so a
+// captured request can't be replayed while its timestamp is still valid.
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Add records the signature until its expiry. It returns false when the
+// signature has already been seen and has not expired yet.
+func (c *nonceCache) Add(signature string, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	if seenExpiry, ok := c.seen[signature]; ok && now.Before(seenExpiry) {
+		return false
+	}
+	c.seen[signature] = expiry
+	return true
+}
+
+// sweep drops the expired signatures, at most once a minute.
+func (c *nonceCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+
+	for signature, expiry := range c.seen {
+		if !now.Before(expiry) {
+			delete(c.seen, signature)
+		}
+	}
+}
@@ -1,45 +1,172 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/slack-go/slack"
 )
 
+const (
+	hSignature = "X-Slack-Signature"
+	hTimestamp = "X-Slack-Request-Timestamp"
+)
+
+const (
+	// MaxBodySize is the largest request body accepted, well above the
+	// slash commands, interactive payloads and events sent by Slack
+	MaxBodySize = 1 << 20
+
+	// ContentTypeForm is the content type of slash commands and interactive
+	// payloads
+	ContentTypeForm = "application/x-www-form-urlencoded"
+	// ContentTypeJSON is the content type of Events API requests
+	ContentTypeJSON = "application/json"
+)
+
 var (
+	// maxTimeElapsed is how old a request may be, as enforced by slack-go
 	maxTimeElapsed = 5 * time.Minute
 )
 
+// SigningSecret is a Slack signing secret accepted by VerifySlackSignatures.
+type SigningSecret struct {
+	Secret string
+	// ExpiresAt is when the secret stops being accepted, zero when never
+	ExpiresAt time.Time
+}
+
+// ParseSigningSecret reads a secret optionally followed by "@" and its expiry,
+// as an RFC 3339 time or a date, e.g. "8f14e45f...@2025-06-30".
+func ParseSigningSecret(value string) (SigningSecret, error) {
+	secret, expiry, found := strings.Cut(value, "@")
+	if secret == "" {
+		return SigningSecret{}, fmt.Errorf("empty signing secret")
+	}
+	if !found {
+		return SigningSecret{Secret: secret}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if expiresAt, err := time.Parse(layout, expiry); err == nil {
+			return SigningSecret{Secret: secret, ExpiresAt: expiresAt}, nil
+		}
+	}
+	return SigningSecret{}, fmt.Errorf("invalid signing secret expiry %q, expected an RFC 3339 time or a date", expiry)
+}
+
+// fingerprint identifies the secret in the logs without disclosing it.
+func (s SigningSecret) fingerprint() string {
+	sum := sha256.Sum256([]byte(s.Secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// VerifySlackSignature rejects the requests that are not signed with the
+// signing secret, are older than maxTimeElapsed, or replay a request already
+// accepted. As Slack only POSTs forms and JSON, other methods and content
+// types are rejected upfront, and so are the bodies over MaxBodySize.
 func VerifySlackSignature(signingSecret string, next http.Handler) http.Handler {
+	var secrets []SigningSecret
+	if signingSecret != "" {
+		secrets = append(secrets, SigningSecret{Secret: signingSecret})
+	}
+	return VerifySlackSignatures(secrets, next)
+}
+
+// VerifySlackSignatures is VerifySlackSignature accepting any of the given
+// secrets until they expire, so the secret can be rotated without
+// synchronizing the deployment with Slack. The current secret comes first;
+// requests signed with another one are logged so it can be retired once no
+// longer used.
+func VerifySlackSignatures(secrets []SigningSecret, next http.Handler) http.Handler {
+	nonces := newNonceCache()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if signingSecret == "" {
+		active := activeSecrets(secrets, time.Now())
+		if len(active) == 0 {
 			http.Error(w, "Slack signing secret not configured", http.StatusInternalServerError)
 			return
 		}
 
-		body, err := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !hasContentType(r, ContentTypeForm, ContentTypeJSON) {
+			http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
 		if err != nil {
-			slog.Error("Error reading rsequest body", "error", err, "body", string(body))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				slog.WarnContext(r.Context(), "Request body too large", "path", r.URL.Path, "limit", tooLarge.Limit)
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			slog.ErrorContext(r.Context(), "Error reading request body", "error", err)
 			http.Error(w, "Error reading request body", http.StatusInternalServerError)
 			return
 		}
 
-		sv, err := slack.NewSecretsVerifier(r.Header, signingSecret)
-		if err != nil {
-			slog.Error("Error creating secret verifier", "body", string(body), "headers", r.Header, "error", err)
+		matched := -1
+		for i, secret := range active {
+			sv, err := slack.NewSecretsVerifier(r.Header, secret.Secret)
+			if err != nil {
+				// The headers are missing or the timestamp is too old, no
+				// secret would match
+				slog.WarnContext(r.Context(), "Error creating secret verifier", "error", err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if _, err := sv.Write(body); err != nil {
+				slog.ErrorContext(r.Context(), "Error writing body to secrets verifier", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if sv.Ensure() == nil {
+				matched = i
+				break
+			}
+		}
+		if matched < 0 {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		if _, err := sv.Write(body); err != nil {
-			slog.Error("Error writing body to secrets verifier", "body", string(body), "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		if matched > 0 {
+			slog.InfoContext(
+				r.Context(),
+				"Slack request signed with a previous signing secret",
+				"secret", active[matched].fingerprint(),
+				"expiresAt", active[matched].ExpiresAt,
+			)
 		}
-		if err := sv.Ensure(); err != nil {
+
+		// The signature covers the timestamp, which slack-go accepts within
+		// maxTimeElapsed of the current time. Past that, a replay is rejected
+		// anyway and the signature can be forgotten.
+		timestamp, _ := strconv.ParseInt(r.Header.Get(hTimestamp), 10, 64)
+		expiry := time.Unix(timestamp, 0).Add(maxTimeElapsed)
+		if !nonces.Add(signatureKey(r.Header.Get(hSignature)), expiry) {
+			slog.WarnContext(
+				r.Context(),
+				"Replayed Slack request rejected",
+				"path", r.URL.Path,
+				"timestamp", timestamp,
+				"remoteAddr", r.RemoteAddr,
+			)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -50,3 +177,47 @@ func VerifySlackSignature(signingSecret string, next http.Handler) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// signatureKey normalizes a verified signature the way slack-go reads it,
+// without the optional v0= prefix and in lowercase hex, so that a replay
+// can't get past the nonce cache by writing the same signature differently.
+func signatureKey(signature string) string {
+	decoded, err := hex.DecodeString(strings.TrimPrefix(signature, "v0="))
+	if err != nil {
+		return signature
+	}
+	return "v0=" + hex.EncodeToString(decoded)
+}
+
+func activeSecrets(secrets []SigningSecret, now time.Time) []SigningSecret {
+	active := make([]SigningSecret, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret.Secret == "" || (!secret.ExpiresAt.IsZero() && now.After(secret.ExpiresAt)) {
+			continue
+		}
+		active = append(active, secret)
+	}
+	return active
+}
+
+// RequireContentType rejects the requests whose body is not of one of the
+// given media types with 415 Unsupported Media Type.
+func RequireContentType(next http.Handler, mediaTypes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasContentType(r, mediaTypes...) {
+			http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasContentType reports whether the media type of the request body, its
+// parameters such as the charset ignored, is one of the given ones.
+func hasContentType(r *http.Request, mediaTypes ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(mediaTypes, mediaType)
+}
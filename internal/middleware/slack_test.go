@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -70,3 +71,197 @@ func TestVerifySlackSignature_ExpiredTimestamp(t *testing.T) {
 		t.Errorf("expected status Unauthorized for expired timestamp, got %v", rr.Code)
 	}
 }
+
+func TestVerifySlackSignature_Replay(t *testing.T) {
+	handler, req := setupTest()
+	replay := req.Clone(req.Context())
+	replay.Body = io.NopCloser(strings.NewReader("test-body"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, replay)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status Unauthorized for a replayed request, got %v", rr.Code)
+	}
+}
+
+func TestVerifySlackSignature_ReplayRewritten(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite func(signature string) string
+	}{
+		{"without prefix", func(signature string) string { return strings.TrimPrefix(signature, "v0=") }},
+		{"uppercase hex", func(signature string) string { return "v0=" + strings.ToUpper(strings.TrimPrefix(signature, "v0=")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, req := setupTest()
+			replay := req.Clone(req.Context())
+			replay.Body = io.NopCloser(strings.NewReader("test-body"))
+			replay.Header.Set("X-Slack-Signature", tt.rewrite(req.Header.Get("X-Slack-Signature")))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status OK, got %v", rr.Code)
+			}
+
+			rr = httptest.NewRecorder()
+			handler.ServeHTTP(rr, replay)
+			if rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected status Unauthorized for a rewritten replay, got %v", rr.Code)
+			}
+		})
+	}
+}
+
+func TestNonceCache_Expiry(t *testing.T) {
+	now := time.Now()
+	cache := newNonceCache()
+	cache.now = func() time.Time { return now }
+
+	if !cache.Add("v0=abc", now.Add(maxTimeElapsed)) {
+		t.Fatal("expected the first signature to be accepted")
+	}
+	if cache.Add("v0=abc", now.Add(maxTimeElapsed)) {
+		t.Error("expected the duplicate signature to be rejected")
+	}
+
+	now = now.Add(maxTimeElapsed + time.Minute)
+	if !cache.Add("v0=abc", now.Add(maxTimeElapsed)) {
+		t.Error("expected the expired signature to be forgotten")
+	}
+	cache.Add("v0=def", now.Add(time.Second))
+	now = now.Add(2 * time.Minute)
+	cache.Add("v0=ghi", now.Add(maxTimeElapsed))
+	if _, ok := cache.seen["v0=def"]; ok {
+		t.Error("expected the expired signature to be swept")
+	}
+}
+
+func TestVerifySlackSignatures_Rotation(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := VerifySlackSignatures([]SigningSecret{
+		{Secret: "current-secret"},
+		{Secret: mockSigningSecret, ExpiresAt: time.Now().Add(time.Hour)},
+		{Secret: "expired-secret", ExpiresAt: time.Now().Add(-time.Hour)},
+	}, testHandler)
+
+	tests := []struct {
+		secret string
+		want   int
+	}{
+		{"current-secret", http.StatusOK},
+		{mockSigningSecret, http.StatusOK},
+		{"expired-secret", http.StatusUnauthorized},
+		{"unknown-secret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, utils.CreateValidSlackRequest(tt.secret, "body-"+tt.secret))
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestParseSigningSecret(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    SigningSecret
+		wantErr bool
+	}{
+		{value: "abc", want: SigningSecret{Secret: "abc"}},
+		{value: "abc@2025-06-30", want: SigningSecret{Secret: "abc", ExpiresAt: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)}},
+		{value: "abc@2025-06-30T12:00:00Z", want: SigningSecret{Secret: "abc", ExpiresAt: time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)}},
+		{value: "abc@tomorrow", wantErr: true},
+		{value: "@2025-06-30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSigningSecret(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got.Secret != tt.want.Secret || !got.ExpiresAt.Equal(tt.want.ExpiresAt) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifySlackSignature_RequestChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{name: "form", method: http.MethodPost, contentType: ContentTypeForm, body: "text=all", want: http.StatusOK},
+		{name: "json with charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: "{}", want: http.StatusOK},
+		{name: "get", method: http.MethodGet, contentType: ContentTypeForm, body: "text=all", want: http.StatusMethodNotAllowed},
+		{name: "put", method: http.MethodPut, contentType: ContentTypeForm, body: "text=all", want: http.StatusMethodNotAllowed},
+		{name: "plain text", method: http.MethodPost, contentType: "text/plain", body: "text=all", want: http.StatusUnsupportedMediaType},
+		{name: "no content type", method: http.MethodPost, body: "text=all", want: http.StatusUnsupportedMediaType},
+		{name: "body at the limit", method: http.MethodPost, contentType: ContentTypeForm, body: strings.Repeat("a", MaxBodySize), want: http.StatusOK},
+		{name: "body too large", method: http.MethodPost, contentType: ContentTypeForm, body: strings.Repeat("a", MaxBodySize+1), want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := setupTest()
+			req := utils.CreateValidSlackRequest(mockSigningSecret, tt.body)
+			req.Method = tt.method
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, rr.Code)
+			}
+			if tt.want == http.StatusMethodNotAllowed && rr.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("expected Allow header POST, got %q", rr.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	handler := RequireContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), ContentTypeJSON)
+
+	tests := []struct {
+		contentType string
+		want        int
+	}{
+		{ContentTypeJSON, http.StatusOK},
+		{ContentTypeForm, http.StatusUnsupportedMediaType},
+		{"application/json;;", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, rr.Code)
+			}
+		})
+	}
+}
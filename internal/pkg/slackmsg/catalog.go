@@ -0,0 +1,208 @@
+package slackmsg
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLanguage is used for the users whose language has no bundle.
+const DefaultLanguage = "en"
+
+// MessageKey identifies a message of the catalog.
+type MessageKey string
+
+// Keys of the messages of the catalog. Every bundle translates all of them.
+const (
+	MsgRefresh        MessageKey = "refresh"
+	MsgShare          MessageKey = "share"
+	MsgPrevious       MessageKey = "previous"
+	MsgNext           MessageKey = "next"
+	MsgOpenSchedule   MessageKey = "open_schedule"
+	MsgUntil          MessageKey = "until"
+	MsgUpdated        MessageKey = "updated"
+	MsgSharedBy       MessageKey = "shared_by"
+	MsgNoOne          MessageKey = "no_one"
+	MsgMoreResponders MessageKey = "more_responders"
+	MsgSchedulesRange MessageKey = "schedules_range"
+	MsgError          MessageKey = "error"
+
+	// Replies to the slash commands
+	MsgNotInstalled      MessageKey = "not_installed"
+	MsgErrorUnauthorized MessageKey = "error_unauthorized"
+	MsgErrorThrottled    MessageKey = "error_throttled"
+	MsgUnknownCommand    MessageKey = "unknown_command"
+	MsgUnknownSubcommand MessageKey = "unknown_subcommand"
+	MsgDidYouMean        MessageKey = "did_you_mean"
+	MsgSeeHelp           MessageKey = "see_help"
+	MsgOr                MessageKey = "or"
+	MsgHelpTitle         MessageKey = "help_title"
+	MsgHelpOncall        MessageKey = "help_oncall"
+	MsgHelpAll           MessageKey = "help_all"
+	MsgHelpRefresh       MessageKey = "help_refresh"
+	MsgHelpPublic        MessageKey = "help_public"
+	MsgHelpPrivate       MessageKey = "help_private"
+	MsgHelpConfig        MessageKey = "help_config"
+	MsgHelpAudit         MessageKey = "help_audit"
+	MsgHelpHelp          MessageKey = "help_help"
+	MsgDenied            MessageKey = "denied"
+	MsgActionConfig      MessageKey = "action_config"
+	MsgActionRefresh     MessageKey = "action_refresh"
+	MsgActionAudit       MessageKey = "action_audit"
+	MsgEveryone          MessageKey = "everyone"
+	MsgServerAdmins      MessageKey = "server_admins"
+	MsgWorkspaceAdmins   MessageKey = "workspace_admins"
+	MsgUsergroupMembers  MessageKey = "usergroup_members"
+	MsgInChannels        MessageKey = "in_channels"
+	MsgConfigUsage       MessageKey = "config_usage"
+	MsgConfigDisabled    MessageKey = "config_disabled"
+	MsgUnknownSchedules  MessageKey = "unknown_schedules"
+	MsgThisChannel       MessageKey = "this_channel"
+	MsgThisWorkspace     MessageKey = "this_workspace"
+	MsgSchedulesSet      MessageKey = "schedules_set"
+	MsgSchedulesUnset    MessageKey = "schedules_unset"
+	MsgVisibilitySet     MessageKey = "visibility_set"
+	MsgChannelSettings   MessageKey = "channel_settings"
+	MsgWorkspaceSettings MessageKey = "workspace_settings"
+	MsgEverySchedule     MessageKey = "every_schedule"
+	MsgAnswered          MessageKey = "answered"
+	MsgVisibilityPublic  MessageKey = "visibility_public"
+	MsgVisibilityPrivate MessageKey = "visibility_private"
+	MsgAuditUsage        MessageKey = "audit_usage"
+	MsgAuditDisabled     MessageKey = "audit_disabled"
+	MsgAuditEmpty        MessageKey = "audit_empty"
+	MsgAuditEntry        MessageKey = "audit_entry"
+)
+
+// bundles holds a JSON file per language, named after it.
+//
+//go:embed locales/*.json
+var bundles embed.FS
+
+// bundle holds the messages of a language, and how it writes dates.
+type bundle struct {
+	Messages map[MessageKey]string `json:"messages"`
+	// Weekdays are the names of the days, starting on Sunday
+	Weekdays []string `json:"weekdays"`
+	Months   []string `json:"months"`
+	// FirstDay replaces the first day of the month when set, e.g. 1er
+	FirstDay string `json:"first_day,omitempty"`
+	// DateTime places the {weekday}, {day}, {month}, {time} and {zone} of a
+	// date
+	DateTime string `json:"datetime"`
+	// Time is the layout of the time of day
+	Time string `json:"time"`
+}
+
+// catalog maps the languages to their bundle.
+var catalog = loadCatalog()
+
+func loadCatalog() map[string]bundle {
+	files, err := bundles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("error listing the message bundles: %v", err))
+	}
+
+	catalog := make(map[string]bundle, len(files))
+	for _, file := range files {
+		data, err := bundles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("error reading the message bundle %s: %v", file.Name(), err))
+		}
+		var b bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			panic(fmt.Sprintf("error decoding the message bundle %s: %v", file.Name(), err))
+		}
+		catalog[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = b
+	}
+	return catalog
+}
+
+// Languages returns the languages of the catalog.
+func Languages() []string {
+	languages := make([]string, 0, len(catalog))
+	for language := range catalog {
+		languages = append(languages, language)
+	}
+	return languages
+}
+
+// Localizer renders the messages in the language and the timezone of a user.
+// The zero value renders them in DefaultLanguage and UTC.
+type Localizer struct {
+	language string
+	location *time.Location
+}
+
+// NewLocalizer returns the localizer of a Slack user, given their locale, e.g.
+// fr-CA, and timezone, e.g. America/Toronto. Unknown languages fall back to
+// DefaultLanguage and unknown timezones to UTC.
+func NewLocalizer(locale, timezone string) Localizer {
+	language, _, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	language = strings.ToLower(language)
+	if _, ok := catalog[language]; !ok {
+		language = DefaultLanguage
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		location = time.UTC
+	}
+	return Localizer{language: language, location: location}
+}
+
+// Language returns the language the messages are rendered in.
+func (l Localizer) Language() string {
+	if l.language == "" {
+		return DefaultLanguage
+	}
+	return l.language
+}
+
+// Location returns the timezone the dates are rendered in.
+func (l Localizer) Location() *time.Location {
+	if l.location == nil {
+		return time.UTC
+	}
+	return l.location
+}
+
+// Text renders a message of the catalog with its arguments, as fmt.Sprintf.
+// Messages missing from the bundle of the language are rendered in
+// DefaultLanguage.
+func (l Localizer) Text(key MessageKey, args ...any) string {
+	format, ok := catalog[l.Language()].Messages[key]
+	if !ok {
+		format, ok = catalog[DefaultLanguage].Messages[key]
+	}
+	if !ok {
+		return string(key)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// DateTime renders a date in the timezone of the user, e.g. "Monday, June 30
+// at 2:00 PM EDT".
+func (l Localizer) DateTime(t time.Time) string {
+	b, ok := catalog[l.Language()]
+	if !ok {
+		b = catalog[DefaultLanguage]
+	}
+	t = t.In(l.Location())
+	day := strconv.Itoa(t.Day())
+	if t.Day() == 1 && b.FirstDay != "" {
+		day = b.FirstDay
+	}
+
+	return strings.NewReplacer(
+		"{weekday}", b.Weekdays[t.Weekday()],
+		"{day}", day,
+		"{month}", b.Months[t.Month()-1],
+		"{time}", t.Format(b.Time),
+		"{zone}", t.Format("MST"),
+	).Replace(b.DateTime)
+}
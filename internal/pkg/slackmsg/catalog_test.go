@@ -0,0 +1,140 @@
+package slackmsg
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/domain"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// givenLocalizedSchedules holds every string of the catalog: the end of a
+// shift, a schedule without responder, and a second page.
+func givenLocalizedSchedules() domain.CurrentOnCallSchedule {
+	s := givenSchedules(SchedulesPerPage + 1)
+	s.UpdatedAt = time.Date(2025, 6, 30, 17, 55, 0, 0, time.UTC)
+	s.Schedules[SchedulesPerPage] = domain.Schedule{
+		Name:     "Platform",
+		URL:      "https://site.example/compass/schedules/platform",
+		ShiftEnd: time.Date(2025, 6, 30, 18, 0, 0, 0, time.UTC),
+	}
+	return s
+}
+
+func TestToBlocks_Golden(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		localizer Localizer
+	}{
+		{"en", NewLocalizer("en-US", "America/New_York")},
+		{"fr", NewLocalizer("fr-CA", "America/Toronto")},
+		{"default", Localizer{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			blocks := ToBlocks(
+				givenLocalizedSchedules(),
+				WithActions("all"),
+				WithPage(1),
+				SharedBy("U123"),
+				WithLocalizer(tc.localizer),
+			)
+			got, err := json.MarshalIndent(blocks, "", "  ")
+			if err != nil {
+				t.Fatalf("failed to encode the blocks: %v", err)
+			}
+
+			golden := filepath.Join("testdata", tc.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, append(got, '\n'), 0o644); err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s, run the tests with -update: %v", golden, err)
+			}
+			if string(append(got, '\n')) != string(want) {
+				t.Errorf("blocks differ from %s, run the tests with -update to review the changes:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestCatalog_Complete(t *testing.T) {
+	keys := []MessageKey{
+		MsgRefresh, MsgShare, MsgPrevious, MsgNext, MsgOpenSchedule, MsgUntil,
+		MsgUpdated, MsgSharedBy, MsgNoOne, MsgMoreResponders, MsgSchedulesRange, MsgError,
+		MsgNotInstalled, MsgErrorUnauthorized, MsgErrorThrottled, MsgUnknownCommand,
+		MsgUnknownSubcommand, MsgDidYouMean, MsgSeeHelp, MsgOr, MsgHelpTitle, MsgHelpOncall,
+		MsgHelpAll, MsgHelpRefresh, MsgHelpPublic, MsgHelpPrivate, MsgHelpConfig, MsgHelpAudit,
+		MsgHelpHelp, MsgDenied, MsgActionConfig, MsgActionRefresh, MsgActionAudit, MsgEveryone,
+		MsgServerAdmins, MsgWorkspaceAdmins, MsgUsergroupMembers, MsgInChannels,
+		MsgConfigUsage, MsgConfigDisabled, MsgUnknownSchedules, MsgThisChannel,
+		MsgThisWorkspace, MsgSchedulesSet, MsgSchedulesUnset, MsgVisibilitySet,
+		MsgChannelSettings, MsgWorkspaceSettings, MsgEverySchedule, MsgAnswered,
+		MsgVisibilityPublic, MsgVisibilityPrivate, MsgAuditUsage, MsgAuditDisabled,
+		MsgAuditEmpty, MsgAuditEntry,
+	}
+	languages := Languages()
+	if !slices.Contains(languages, "en") || !slices.Contains(languages, "fr") {
+		t.Fatalf("expected English and French bundles, got %v", languages)
+	}
+
+	for _, language := range languages {
+		b := catalog[language]
+		for _, key := range keys {
+			if b.Messages[key] == "" {
+				t.Errorf("expected %s to translate %q", language, key)
+			}
+		}
+		if len(b.Messages) != len(keys) {
+			t.Errorf("expected %d messages in %s, got %d", len(keys), language, len(b.Messages))
+		}
+		if len(b.Weekdays) != 7 || len(b.Months) != 12 || b.DateTime == "" || b.Time == "" {
+			t.Errorf("expected %s to tell how to write dates", language)
+		}
+	}
+}
+
+func TestNewLocalizer(t *testing.T) {
+	for _, tc := range []struct {
+		locale, timezone string
+		language         string
+		location         string
+	}{
+		{"fr-CA", "America/Toronto", "fr", "America/Toronto"},
+		{"fr_FR", "Europe/Paris", "fr", "Europe/Paris"},
+		{"en-GB", "Europe/London", "en", "Europe/London"},
+		{"de-DE", "Europe/Berlin", "en", "Europe/Berlin"},
+		{"", "Mars/Olympus_Mons", "en", "UTC"},
+		{"", "", "en", "UTC"},
+	} {
+		l := NewLocalizer(tc.locale, tc.timezone)
+		if l.Language() != tc.language || l.Location().String() != tc.location {
+			t.Errorf("expected %s and %s for %q and %q, got %s and %s", tc.language, tc.location, tc.locale, tc.timezone, l.Language(), l.Location())
+		}
+	}
+}
+
+func TestLocalizer_DateTime(t *testing.T) {
+	date := time.Date(2025, 12, 31, 23, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		localizer Localizer
+		expected  string
+	}{
+		{Localizer{}, "Wednesday, December 31 at 11:30 PM UTC"},
+		{NewLocalizer("en-US", "America/Los_Angeles"), "Wednesday, December 31 at 3:30 PM PST"},
+		{NewLocalizer("fr-FR", "Europe/Paris"), "jeudi 1er janvier à 00 h 30 CET"},
+	} {
+		if got := tc.localizer.DateTime(date); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
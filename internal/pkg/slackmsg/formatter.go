@@ -1,52 +1,384 @@
 package slackmsg
 
 import (
-	"encoding/json"
 	"fmt"
-	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/metriodev/pompiers/internal/domain"
 	"github.com/slack-go/slack"
 )
 
-func ToSlackMessage(s domain.CurrentOnCallSchedule) ([]byte, error) {
-	var elements []slack.RichTextElement
-	for _, schedule := range s.Schedules {
-		elements = append(elements, scheduleToBlock(schedule))
+// Block and action IDs of the interactive components. The action IDs are
+// prefixed with the ID of their block, so the interactions can be routed on the
+// action ID alone.
+const (
+	BlockIDActions       = "oncall_actions"
+	ActionIDRefresh      = BlockIDActions + ".refresh"
+	ActionIDShare        = BlockIDActions + ".share"
+	ActionIDPreviousPage = BlockIDActions + ".previous"
+	ActionIDNextPage     = BlockIDActions + ".next"
+	// ActionIDScheduleLink is the button opening a schedule in Compass. Slack
+	// still sends an interaction when it is clicked.
+	ActionIDScheduleLink = "schedule.link"
+)
+
+const (
+	// channelValuePlaceholder stands for the values of the buttons when
+	// measuring them
+	channelValuePlaceholder = "channel"
+	// MaxBlocks is the number of blocks Slack accepts in a message
+	MaxBlocks = 50
+	// SchedulesPerPage is the most schedules of a page, leaving room for the
+	// footer and the buttons when each schedule takes two blocks. Pages hold
+	// fewer when they would outgrow a message.
+	SchedulesPerPage = 20
+	// maxAvatars leaves room for the names in the context of a schedule,
+	// which holds up to 10 elements
+	maxAvatars = 9
+)
+
+// MessageOption customizes the messages built from the schedules.
+type MessageOption func(*messageOptions)
+
+type messageOptions struct {
+	actions   bool
+	value     string
+	page      int
+	sharedBy  string
+	inChannel bool
+	localizer Localizer
+}
+
+// WithActions adds the refresh and share buttons, and the buttons turning the
+// pages. The value is sent back when they are clicked, e.g. to fetch the same
+// schedules again, along with the page, see ParseActionValue.
+func WithActions(value string) MessageOption {
+	return func(o *messageOptions) {
+		o.actions = true
+		o.value = value
 	}
+}
 
-	message := slack.NewBlockMessage(
-		slack.NewRichTextBlock(
-			"rich_text",
-			slack.NewRichTextList(slack.RTEListBullet, 0, elements...),
-		),
-	)
+// WithPage shows the given page of the schedules, starting at 0. Pages past
+// the last one show the last one.
+func WithPage(page int) MessageOption {
+	return func(o *messageOptions) {
+		o.page = page
+	}
+}
+
+// InChannel leaves the share button out of the messages already posted in the
+// channel.
+func InChannel() MessageOption {
+	return func(o *messageOptions) {
+		o.inChannel = true
+	}
+}
+
+// SharedBy credits the user who shared the schedules to the channel.
+func SharedBy(userID string) MessageOption {
+	return func(o *messageOptions) {
+		o.sharedBy = userID
+	}
+}
+
+// WithLocalizer renders the messages in the language and the timezone of the
+// user. They are rendered in DefaultLanguage and UTC otherwise.
+func WithLocalizer(l Localizer) MessageOption {
+	return func(o *messageOptions) {
+		o.localizer = l
+	}
+}
+
+// actionValue appends the page to the value of the buttons. The first page is
+// left out.
+func actionValue(value string, page int) string {
+	if page == 0 {
+		return value
+	}
+	return fmt.Sprintf("%s:%d", value, page)
+}
+
+// ParseActionValue splits the value of a button into the value given to
+// WithActions and the page the button belongs to.
+func ParseActionValue(value string) (string, int) {
+	prefix, suffix, found := strings.Cut(value, ":")
+	if !found {
+		return value, 0
+	}
+	page, err := strconv.Atoi(suffix)
+	if err != nil || page < 0 {
+		return prefix, 0
+	}
+	return prefix, page
+}
+
+// Pages returns how many pages the schedules take.
+func Pages(s domain.CurrentOnCallSchedule, opts ...MessageOption) int {
+	return len(paginate(s, newMessageOptions(opts).localizer).starts) - 1
+}
+
+// ToBlocks returns the blocks listing the schedules. Each schedule gets a
+// section, with its link and the end of the shift, and the avatars of the
+// responders below. The schedules are paginated for a page to fit in a single
+// message, see ToMessages for the schedules outgrowing a message on their own.
+func ToBlocks(s domain.CurrentOnCallSchedule, opts ...MessageOption) []slack.Block {
+	schedules, trailer, actions := render(s, opts...)
+
+	var blocks []slack.Block
+	for _, schedule := range schedules {
+		blocks = append(blocks, schedule...)
+	}
+	blocks = append(blocks, trailer...)
+	if actions != nil {
+		blocks = append(blocks, actions)
+	}
+	return blocks
+}
 
-	// response := map[string]interface{}{
-	// 	"response_type": slack.ResponseTypeEphemeral,
-	// 	"blocks":        message.Blocks,
-	// }
+func newMessageOptions(opts []MessageOption) *messageOptions {
+	options := &messageOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
 
-	// message.Type = slack.ResponseTypeEphemeral
+// pagination holds the index of the first schedule of every page, followed
+// by the number of schedules, and the room the footer and the buttons may
+// take in a message.
+type pagination struct {
+	starts        []int
+	reserveBlocks int
+	reserveSize   int
+}
 
-	payload, err := json.Marshal(&message)
-	if err != nil {
-		slog.Error("Error marshalling slack message", "error", err)
-		return nil, fmt.Errorf("failed to marshal slack message: %w", err)
+// paginate splits the schedules into pages of at most SchedulesPerPage
+// schedules fitting in a single message along with the footer and the
+// buttons, so the buttons replace the whole page in place. A schedule
+// outgrowing a message on its own gets a page of its own. The pages don't
+// depend on the buttons, a shared page holds the same schedules as the page
+// shown to the user.
+func paginate(s domain.CurrentOnCallSchedule, l Localizer) pagination {
+	// The largest footer and buttons the pages may get
+	reserve := []slack.Block{
+		footer(s, len(s.Schedules), len(s.Schedules), 2, l),
+		sharedByBlock(strings.Repeat("U", 20), l),
+		actionsBlock(channelValuePlaceholder, 1, 3, true, l),
+	}
+	p := pagination{starts: []int{0}}
+	for _, block := range reserve {
+		if block != nil {
+			p.reserveBlocks++
+			p.reserveSize += encodedSize(block)
+		}
 	}
 
-	return payload, nil
+	count, blocks, size := 0, 0, 0
+	for i, schedule := range s.Schedules {
+		scheduleBlocks := scheduleToBlocks(schedule, l, len(schedule.Responders))
+		scheduleSize := blocksSize(scheduleBlocks)
+		if count > 0 && (count == SchedulesPerPage ||
+			blocks+len(scheduleBlocks)+p.reserveBlocks > MaxBlocks ||
+			size+scheduleSize+p.reserveSize > MaxMessageSize) {
+			p.starts = append(p.starts, i)
+			count, blocks, size = 0, 0, 0
+		}
+		count++
+		blocks += len(scheduleBlocks)
+		size += scheduleSize
+	}
+	if len(s.Schedules) > 0 {
+		p.starts = append(p.starts, len(s.Schedules))
+	} else {
+		p.starts = append(p.starts, 0)
+	}
+	return p
 }
 
-func scheduleToBlock(schedule domain.Schedule) *slack.RichTextSection {
-	return slack.NewRichTextSection(
-		slack.NewRichTextSectionTextElement(
-			fmt.Sprintf("%s: ", schedule.Name),
-			&slack.RichTextSectionTextStyle{Bold: true},
-		),
-		slack.NewRichTextSectionTextElement(
-			schedule.OnCallUsers,
-			&slack.RichTextSectionTextStyle{},
-		),
+// render returns the blocks of every schedule of the page, the blocks
+// following them, and the buttons, nil without WithActions. With the buttons,
+// the responders of a schedule outgrowing a message on its own are cut short
+// for the page to fit in a single message.
+func render(s domain.CurrentOnCallSchedule, opts ...MessageOption) ([][]slack.Block, []slack.Block, slack.Block) {
+	options := newMessageOptions(opts)
+	l := options.localizer
+	p := paginate(s, l)
+	pages := len(p.starts) - 1
+	page := min(max(options.page, 0), pages-1)
+
+	first, last := p.starts[page], p.starts[page+1]
+
+	var schedules [][]slack.Block
+	for _, schedule := range s.Schedules[first:last] {
+		blocks := scheduleToBlocks(schedule, l, len(schedule.Responders))
+		if options.actions && last-first == 1 {
+			blocks = fitSchedule(schedule, l, MaxBlocks-p.reserveBlocks, MaxMessageSize-p.reserveSize)
+		}
+		schedules = append(schedules, blocks)
+	}
+
+	var trailer []slack.Block
+	if footer := footer(s, first, last, pages, l); footer != nil {
+		trailer = append(trailer, footer)
+	}
+	if options.sharedBy != "" {
+		trailer = append(trailer, sharedByBlock(options.sharedBy, l))
+	}
+	var actions slack.Block
+	if options.actions {
+		actions = actionsBlock(options.value, page, pages, !options.inChannel, l)
+	}
+	return schedules, trailer, actions
+}
+
+// fitSchedule returns the blocks of the schedule, with as many responders as
+// fit in the given room, the others being counted.
+func fitSchedule(schedule domain.Schedule, l Localizer, maxBlocks, maxSize int) []slack.Block {
+	fits := func(blocks []slack.Block) bool {
+		return len(blocks) <= maxBlocks && blocksSize(blocks) <= maxSize
+	}
+	if blocks := scheduleToBlocks(schedule, l, len(schedule.Responders)); fits(blocks) {
+		return blocks
+	}
+
+	// The most responders fitting, 0 always does
+	shown, hidden := 0, len(schedule.Responders)
+	for shown+1 < hidden {
+		middle := (shown + hidden) / 2
+		if fits(scheduleToBlocks(schedule, l, middle)) {
+			shown = middle
+		} else {
+			hidden = middle
+		}
+	}
+	return scheduleToBlocks(schedule, l, shown)
+}
+
+func sharedByBlock(userID string, l Localizer) *slack.ContextBlock {
+	return slack.NewContextBlock(
+		"shared_by",
+		slack.NewTextBlockObject(slack.MarkdownType, l.Text(MsgSharedBy, userID), false, false),
 	)
 }
+
+// actionsBlock holds the buttons refreshing the message in place and sharing
+// it to the channel, and the ones turning the pages.
+func actionsBlock(value string, page, pages int, share bool, l Localizer) *slack.ActionBlock {
+	current := actionValue(value, page)
+	elements := []slack.BlockElement{
+		slack.NewButtonBlockElement(ActionIDRefresh, current, slack.NewTextBlockObject(slack.PlainTextType, l.Text(MsgRefresh), false, false)),
+	}
+	if share {
+		elements = append(elements, slack.NewButtonBlockElement(ActionIDShare, current, slack.NewTextBlockObject(slack.PlainTextType, l.Text(MsgShare), false, false)))
+	}
+	if page > 0 {
+		elements = append(elements, slack.NewButtonBlockElement(ActionIDPreviousPage, actionValue(value, page-1), slack.NewTextBlockObject(slack.PlainTextType, l.Text(MsgPrevious), false, false)))
+	}
+	if page < pages-1 {
+		elements = append(elements, slack.NewButtonBlockElement(ActionIDNextPage, actionValue(value, page+1), slack.NewTextBlockObject(slack.PlainTextType, l.Text(MsgNext), false, false)))
+	}
+	return slack.NewActionBlock(BlockIDActions, elements...)
+}
+
+// footer tells how old the schedules are and which of them are shown, nil
+// when there is nothing to tell.
+func footer(s domain.CurrentOnCallSchedule, first, last, pages int, l Localizer) *slack.ContextBlock {
+	var elements []slack.MixedElement
+	if !s.UpdatedAt.IsZero() {
+		elements = append(elements, updatedAt(s.UpdatedAt, l))
+	}
+	if pages > 1 {
+		elements = append(elements, slack.NewTextBlockObject(
+			slack.MarkdownType,
+			l.Text(MsgSchedulesRange, first+1, last, len(s.Schedules)),
+			false,
+			false,
+		))
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+	return slack.NewContextBlock("footer", elements...)
+}
+
+// updatedAt tells how old the schedules are. In English, Slack renders the age
+// and keeps it current, falling back to the date in the user's timezone.
+func updatedAt(updatedAt time.Time, l Localizer) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(
+		slack.MarkdownType,
+		l.Text(MsgUpdated, updatedAt.Unix(), l.DateTime(updatedAt)),
+		false,
+		false,
+	)
+}
+
+// scheduleToBlocks returns the section of a schedule, linking to Compass, and
+// the contexts listing the first shown of its responders. The end of the shift
+// is rendered in the user's timezone.
+func scheduleToBlocks(schedule domain.Schedule, l Localizer, shown int) []slack.Block {
+	text := fmt.Sprintf("*%s*", escape(schedule.Name))
+	if !schedule.ShiftEnd.IsZero() {
+		text += "\n" + l.Text(MsgUntil, l.DateTime(schedule.ShiftEnd))
+	}
+
+	var accessory *slack.Accessory
+	if schedule.URL != "" {
+		accessory = slack.NewAccessory(
+			slack.NewButtonBlockElement(ActionIDScheduleLink, "", slack.NewTextBlockObject(slack.PlainTextType, l.Text(MsgOpenSchedule), false, false)).
+				WithURL(schedule.URL),
+		)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, truncate(text, MaxTextLength), false, false), nil, accessory),
+	}
+	for _, elements := range respondersToElements(schedule, l, shown) {
+		blocks = append(blocks, slack.NewContextBlock("", elements...))
+	}
+	return blocks
+}
+
+// respondersToElements returns the elements of the contexts listing the first
+// shown of the responders: their avatars followed by their names, continued in
+// as many contexts as the names need, and how many responders are left out.
+func respondersToElements(schedule domain.Schedule, l Localizer, shown int) [][]slack.MixedElement {
+	if len(schedule.Responders) == 0 {
+		// Schedules built without responders only have the names
+		names := schedule.OnCallUsers
+		if names == "" {
+			names = l.Text(MsgNoOne)
+		}
+		return [][]slack.MixedElement{{slack.NewTextBlockObject(slack.MarkdownType, truncate(escape(names), MaxTextLength), false, false)}}
+	}
+
+	var avatars []slack.MixedElement
+	names := make([]string, 0, shown+1)
+	for _, responder := range schedule.Responders[:shown] {
+		if responder.AvatarURL != "" && len(avatars) < maxAvatars {
+			avatars = append(avatars, slack.NewImageBlockElement(responder.AvatarURL, responder.Name))
+		}
+		names = append(names, escape(responder.Name))
+	}
+	if hidden := len(schedule.Responders) - shown; hidden > 0 {
+		names = append(names, l.Text(MsgMoreResponders, hidden))
+	}
+
+	chunks := joinNames(names, MaxTextLength)
+	contexts := make([][]slack.MixedElement, 0, len(chunks))
+	for i, chunk := range chunks {
+		var elements []slack.MixedElement
+		if i == 0 {
+			elements = avatars
+		}
+		contexts = append(contexts, append(elements, slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false)))
+	}
+	return contexts
+}
+
+// escape escapes the characters Slack reserves for its markup.
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,151 @@
+package slackmsg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/slack-go/slack"
+)
+
+func givenSchedules(n int) domain.CurrentOnCallSchedule {
+	schedules := make([]domain.Schedule, n)
+	for i := range schedules {
+		schedules[i] = domain.Schedule{
+			Name:       fmt.Sprintf("Schedule %02d", i),
+			Responders: []domain.Responder{{Name: "Test User"}},
+		}
+	}
+	return domain.CurrentOnCallSchedule{Schedules: schedules, UpdatedAt: time.Now()}
+}
+
+func buttons(t *testing.T, blocks []slack.Block) map[string]string {
+	t.Helper()
+
+	actions, ok := blocks[len(blocks)-1].(*slack.ActionBlock)
+	if !ok {
+		t.Fatalf("expected the buttons last, got %T", blocks[len(blocks)-1])
+	}
+	values := make(map[string]string)
+	for _, element := range actions.Elements.ElementSet {
+		button := element.(*slack.ButtonBlockElement)
+		values[button.ActionID] = button.Value
+	}
+	return values
+}
+
+func TestToBlocks_Schedule(t *testing.T) {
+	shiftEnd := time.Date(2025, 6, 30, 18, 0, 0, 0, time.UTC)
+	blocks := ToBlocks(domain.CurrentOnCallSchedule{Schedules: []domain.Schedule{{
+		Name: "Platform <prod>",
+		Responders: []domain.Responder{
+			{Name: "Alice", AvatarURL: "https://avatar.example/alice.png"},
+			{Name: "Bob"},
+		},
+		URL:      "https://site.example/compass/schedules/platform",
+		ShiftEnd: shiftEnd,
+	}}})
+
+	if len(blocks) != 2 {
+		t.Fatalf("expected a section and a context, got %d blocks", len(blocks))
+	}
+
+	section := blocks[0].(*slack.SectionBlock)
+	expected := "*Platform &lt;prod&gt;*\nUntil Monday, June 30 at 6:00 PM UTC"
+	if section.Text.Text != expected {
+		t.Errorf("expected %q, got %q", expected, section.Text.Text)
+	}
+	if section.Accessory == nil || section.Accessory.ButtonElement == nil {
+		t.Fatal("expected a link to the schedule")
+	}
+	if link := section.Accessory.ButtonElement; link.URL != "https://site.example/compass/schedules/platform" || link.ActionID != ActionIDScheduleLink {
+		t.Errorf("expected a link to the schedule, got %+v", section.Accessory)
+	}
+
+	responders := blocks[1].(*slack.ContextBlock).ContextElements.Elements
+	if len(responders) != 2 {
+		t.Fatalf("expected the avatar of Alice and the names, got %+v", responders)
+	}
+	if avatar := responders[0].(*slack.ImageBlockElement); avatar.ImageURL != "https://avatar.example/alice.png" || avatar.AltText != "Alice" {
+		t.Errorf("unexpected avatar %+v", avatar)
+	}
+	if names := responders[1].(*slack.TextBlockObject); names.Text != "Alice, Bob" {
+		t.Errorf("expected the names of the responders, got %q", names.Text)
+	}
+}
+
+func TestToBlocks_NoResponder(t *testing.T) {
+	blocks := ToBlocks(domain.CurrentOnCallSchedule{Schedules: []domain.Schedule{{Name: "Platform"}}})
+
+	section := blocks[0].(*slack.SectionBlock)
+	if section.Accessory != nil {
+		t.Errorf("expected no link without URL, got %+v", section.Accessory)
+	}
+	names := blocks[1].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject)
+	if names.Text != "No one is on call" {
+		t.Errorf("unexpected responders %q", names.Text)
+	}
+}
+
+func TestToBlocks_Pages(t *testing.T) {
+	schedules := givenSchedules(2*SchedulesPerPage + 5)
+	if Pages(schedules) != 3 {
+		t.Fatalf("expected 3 pages, got %d", Pages(schedules))
+	}
+
+	for page, expected := range []map[string]string{
+		{ActionIDRefresh: "all", ActionIDShare: "all", ActionIDNextPage: "all:1"},
+		{ActionIDRefresh: "all:1", ActionIDShare: "all:1", ActionIDPreviousPage: "all", ActionIDNextPage: "all:2"},
+		{ActionIDRefresh: "all:2", ActionIDShare: "all:2", ActionIDPreviousPage: "all:1"},
+	} {
+		blocks := ToBlocks(schedules, WithActions("all"), WithPage(page), SharedBy("U1"))
+		if len(blocks) > MaxBlocks {
+			t.Errorf("expected at most %d blocks on page %d, got %d", MaxBlocks, page, len(blocks))
+		}
+
+		first := blocks[0].(*slack.SectionBlock).Text.Text
+		if name := fmt.Sprintf("*Schedule %02d*", page*SchedulesPerPage); first != name {
+			t.Errorf("expected page %d to start with %s, got %s", page, name, first)
+		}
+
+		values := buttons(t, blocks)
+		if len(values) != len(expected) {
+			t.Errorf("expected the buttons %v on page %d, got %v", expected, page, values)
+		}
+		for actionID, value := range expected {
+			if values[actionID] != value {
+				t.Errorf("expected %s to hold %q on page %d, got %q", actionID, value, page, values[actionID])
+			}
+		}
+	}
+
+	raw, _ := json.Marshal(ToBlocks(schedules, WithPage(1)))
+	if !strings.Contains(string(raw), "Schedules 21-40 of 45") {
+		t.Errorf("expected the footer to tell which schedules are shown, got %s", raw)
+	}
+
+	blocks := ToBlocks(schedules, WithPage(10))
+	if first := blocks[0].(*slack.SectionBlock).Text.Text; first != "*Schedule 40*" {
+		t.Errorf("expected pages past the end to show the last one, got %s", first)
+	}
+}
+
+func TestParseActionValue(t *testing.T) {
+	for value, expected := range map[string]struct {
+		value string
+		page  int
+	}{
+		"channel":   {"channel", 0},
+		"all:2":     {"all", 2},
+		"all:-1":    {"all", 0},
+		"all:wrong": {"all", 0},
+	} {
+		v, page := ParseActionValue(value)
+		if v != expected.value || page != expected.page {
+			t.Errorf("expected %q to be parsed as %v, got %q, %d", value, expected, v, page)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package slackmsg
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// MaxTextLength is the number of characters Slack accepts in the text of
+	// a section or of a context element
+	MaxTextLength = 3000
+	// MaxMessageSize bounds the JSON encoding of the blocks of a message,
+	// keeping it well under the size of the payloads Slack accepts
+	MaxMessageSize = 12000
+
+	ellipsis = "…"
+)
+
+// ToMessages returns the blocks of the page of the schedules split into as
+// many messages as needed to stay under MaxBlocks and MaxMessageSize, to be
+// sent in order. Pages fit in a single message, only a schedule outgrowing a
+// message on its own is split, without WithActions. The blocks of a schedule
+// are kept together unless they outgrow a message, the footer ends the last
+// message and the buttons the first one, the message they replace.
+func ToMessages(s domain.CurrentOnCallSchedule, opts ...MessageOption) [][]slack.Block {
+	schedules, trailer, actions := render(s, opts...)
+
+	// Room left in the message being filled
+	roomBlocks, roomSize := MaxBlocks, MaxMessageSize
+	if actions != nil {
+		roomBlocks, roomSize = roomBlocks-1, roomSize-encodedSize(actions)
+	}
+
+	var messages [][]slack.Block
+	var current []slack.Block
+	flush := func() {
+		messages = append(messages, current)
+		current, roomBlocks, roomSize = nil, MaxBlocks, MaxMessageSize
+	}
+	add := func(blocks []slack.Block) {
+		for _, block := range blocks {
+			blockSize := encodedSize(block)
+			if len(current) > 0 && (roomBlocks == 0 || blockSize > roomSize) {
+				flush()
+			}
+			current = append(current, block)
+			roomBlocks, roomSize = roomBlocks-1, roomSize-blockSize
+		}
+	}
+
+	for _, blocks := range append(schedules, trailer) {
+		if len(current) > 0 && (len(blocks) > roomBlocks || blocksSize(blocks) > roomSize) {
+			flush()
+		}
+		add(blocks)
+	}
+	if len(current) > 0 || len(messages) == 0 {
+		flush()
+	}
+
+	if actions != nil {
+		messages[0] = append(messages[0], actions)
+	}
+	return messages
+}
+
+// blocksSize returns the size of the blocks in the payload of a message.
+func blocksSize(blocks []slack.Block) int {
+	size := 0
+	for _, block := range blocks {
+		size += encodedSize(block)
+	}
+	return size
+}
+
+// encodedSize returns the size of the block in the payload of a message.
+func encodedSize(block slack.Block) int {
+	raw, err := json.Marshal(block)
+	if err != nil {
+		return 0
+	}
+	// The comma separating the block from the next one
+	return len(raw) + 1
+}
+
+// joinNames joins the names with commas into as few texts of at most limit
+// characters as possible. A name longer than the limit is truncated.
+func joinNames(names []string, limit int) []string {
+	var texts []string
+	var current strings.Builder
+	for _, name := range names {
+		name = truncate(name, limit)
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+len(", ")+utf8.RuneCountInString(name) > limit {
+			texts = append(texts, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(", ")
+		}
+		current.WriteString(name)
+	}
+	if current.Len() > 0 {
+		texts = append(texts, current.String())
+	}
+	return texts
+}
+
+// truncate cuts the text to at most limit characters, ending with an
+// ellipsis when cut.
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-utf8.RuneCountInString(ellipsis)]) + ellipsis
+}
@@ -0,0 +1,154 @@
+package slackmsg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/slack-go/slack"
+)
+
+func givenCrowdedSchedules(schedules, responders int) domain.CurrentOnCallSchedule {
+	s := givenSchedules(schedules)
+	for i := range s.Schedules {
+		s.Schedules[i].Responders = make([]domain.Responder, responders)
+		for j := range s.Schedules[i].Responders {
+			s.Schedules[i].Responders[j] = domain.Responder{
+				Name:      fmt.Sprintf("User %03d", j),
+				AvatarURL: fmt.Sprintf("https://avatar.example/%03d.png", j),
+			}
+		}
+	}
+	return s
+}
+
+func TestToMessages_Single(t *testing.T) {
+	schedules := givenSchedules(3)
+
+	messages := ToMessages(schedules, WithActions("all"))
+	if len(messages) != 1 {
+		t.Fatalf("expected a single message, got %d", len(messages))
+	}
+	if len(messages[0]) != len(ToBlocks(schedules, WithActions("all"))) {
+		t.Errorf("expected every block in the message, got %d", len(messages[0]))
+	}
+}
+
+// checkLimits fails when the message is over the limits.
+func checkLimits(t *testing.T, i int, message []slack.Block) {
+	t.Helper()
+
+	if size := blocksSize(message); len(message) > MaxBlocks || size > MaxMessageSize {
+		t.Errorf("expected message %d under the limits, got %d blocks and %d bytes", i, len(message), size)
+	}
+}
+
+func TestToMessages_Paginated(t *testing.T) {
+	schedules := givenCrowdedSchedules(SchedulesPerPage, 400)
+
+	pages := Pages(schedules)
+	if pages < 2 {
+		t.Fatalf("expected the schedules to take several pages, got %d", pages)
+	}
+	next := 0
+	for page := range pages {
+		messages := ToMessages(schedules, WithActions("all"), WithPage(page))
+		if len(messages) != 1 {
+			t.Fatalf("expected page %d in a single message, got %d", page, len(messages))
+		}
+		checkLimits(t, page, messages[0])
+		for _, block := range messages[0] {
+			if section, ok := block.(*slack.SectionBlock); ok {
+				if expected := fmt.Sprintf("*Schedule %02d*", next); section.Text.Text != expected {
+					t.Errorf("expected %s on page %d, got %s", expected, page, section.Text.Text)
+				}
+				next++
+			}
+		}
+		if _, ok := messages[0][len(messages[0])-1].(*slack.ActionBlock); !ok {
+			t.Errorf("expected the buttons to end page %d", page)
+		}
+	}
+	if next != SchedulesPerPage {
+		t.Errorf("expected every schedule once, got %d", next)
+	}
+}
+
+func TestToMessages_Split(t *testing.T) {
+	schedules := givenCrowdedSchedules(1, 3000)
+
+	// Shared to the channel, every responder is listed
+	messages := ToMessages(schedules, SharedBy("U1"))
+	if len(messages) < 2 {
+		t.Fatalf("expected the schedule to be split, got %d message", len(messages))
+	}
+	var blocks []slack.Block
+	for i, message := range messages {
+		checkLimits(t, i, message)
+		blocks = append(blocks, message...)
+	}
+	expected := ToBlocks(schedules, SharedBy("U1"))
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected the %d blocks of the page, got %d", len(expected), len(blocks))
+	}
+	for i := range blocks {
+		got, _ := json.Marshal(blocks[i])
+		want, _ := json.Marshal(expected[i])
+		if string(got) != string(want) {
+			t.Fatalf("expected the blocks in order, block %d differs", i)
+		}
+	}
+
+	// With the buttons, the page stays in the message they replace
+	messages = ToMessages(schedules, WithActions("all"))
+	if len(messages) != 1 {
+		t.Fatalf("expected a single message with the buttons, got %d", len(messages))
+	}
+	checkLimits(t, 0, messages[0])
+	raw, _ := json.Marshal(messages[0])
+	if !strings.Contains(string(raw), "User 000, User 001") || !regexp.MustCompile(`and \d+ more`).Match(raw) {
+		t.Errorf("expected the first responders and how many are left out, got %s", raw)
+	}
+	if _, ok := messages[0][len(messages[0])-1].(*slack.ActionBlock); !ok {
+		t.Errorf("expected the buttons to end the message")
+	}
+}
+
+func TestToBlocks_ManyResponders(t *testing.T) {
+	blocks := ToBlocks(givenCrowdedSchedules(1, 400))
+
+	// 400 names of 8 characters take 2 contexts of at most 3000 characters
+	if len(blocks) != 4 {
+		t.Fatalf("expected a section and 2 contexts before the footer, got %d blocks", len(blocks))
+	}
+	first := blocks[1].(*slack.ContextBlock).ContextElements.Elements
+	if len(first) != maxAvatars+1 {
+		t.Errorf("expected %d avatars and the names, got %d elements", maxAvatars, len(first))
+	}
+
+	var names []string
+	for _, block := range blocks[1:3] {
+		elements := block.(*slack.ContextBlock).ContextElements.Elements
+		text := elements[len(elements)-1].(*slack.TextBlockObject).Text
+		if utf8.RuneCountInString(text) > MaxTextLength {
+			t.Errorf("expected at most %d characters, got %d", MaxTextLength, utf8.RuneCountInString(text))
+		}
+		names = append(names, strings.Split(text, ", ")...)
+	}
+	if len(names) != 400 || names[0] != "User 000" || names[399] != "User 399" {
+		t.Errorf("expected every name once, in order, got %d names", len(names))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if text := truncate("Platform", 20); text != "Platform" {
+		t.Errorf("expected a short text to be kept, got %q", text)
+	}
+	if text := truncate("Équipe plateforme", 8); text != "Équipe …" {
+		t.Errorf("expected the text cut to 8 characters, got %q", text)
+	}
+}
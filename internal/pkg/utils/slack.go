@@ -11,7 +11,8 @@ import (
 	"time"
 )
 
-// Creates a valid Slack request witht proper signatures
+// Creates a valid Slack request witht proper signatures, posting a form as the
+// slash commands and interactive payloads do
 func CreateValidSlackRequest(secret string, body string) *http.Request {
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	baseString := "v0:" + timestamp + ":" + body
@@ -23,6 +24,7 @@ func CreateValidSlackRequest(secret string, body string) *http.Request {
 	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
 	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
 	req.Header.Set("X-Slack-Signature", signature)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	return req
 }
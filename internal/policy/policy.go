@@ -0,0 +1,195 @@
+// Package policy decides who may run the privileged subcommands, from rules
+// keyed on Slack users, usergroups and channels.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// ActionConfig changes the channel and workspace defaults
+	ActionConfig = "config"
+	// ActionRefresh rebuilds the on-call snapshot before answering
+	ActionRefresh = "refresh"
+	// ActionAudit lists the recent entries of the audit log
+	ActionAudit = "audit"
+
+	// AnyAction makes a rule apply to every action
+	AnyAction = "*"
+)
+
+// Actions are the subcommands the rules apply to.
+var Actions = []string{ActionConfig, ActionRefresh, ActionAudit}
+
+// restricted are the actions only the workspace admins may run when no rule
+// applies to them. The other actions are open to everyone by default.
+var restricted = map[string]bool{
+	ActionConfig: true,
+	ActionAudit:  true,
+}
+
+// Rule allows an action to the users matching any of its principals, in the
+// channels it lists. A rule without principal allows everyone in its
+// channels, a rule without channel applies everywhere.
+type Rule struct {
+	// Action is one of Actions, or AnyAction
+	Action string
+	// Team restricts the rule to a workspace, every workspace when empty
+	Team     string
+	Channels []string
+
+	Users []string
+	// Usergroups are usergroup IDs or handles, e.g. "S0123ABCD" or "@sre"
+	Usergroups []string
+	// Admins allows the workspace admins and owners
+	Admins bool
+}
+
+func (r Rule) appliesTo(req Request) bool {
+	return (r.Action == req.Action || r.Action == AnyAction) && (r.Team == "" || r.Team == req.TeamID)
+}
+
+func (r Rule) hasPrincipals() bool {
+	return len(r.Users) > 0 || len(r.Usergroups) > 0 || r.Admins
+}
+
+// Request is a user asking for an action in a channel.
+type Request struct {
+	TeamID    string
+	ChannelID string
+	UserID    string
+	Action    string
+}
+
+// Directory looks up the Slack users and usergroups of the workspace the
+// request comes from.
+type Directory interface {
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+	UsergroupMembers(ctx context.Context, usergroup string) ([]string, error)
+}
+
+// Decision is the outcome of the evaluation of a request.
+type Decision struct {
+	Allowed bool
+	// Rule is the index of the rule allowing the action, -1 when the action
+	// was allowed or denied by default
+	Rule int
+	// Rules are the rules the user matches none of when denied, the default
+	// one included, for the denial to tell who may run the action
+	Rules []Rule
+}
+
+// Policy evaluates the requests against its rules.
+type Policy struct {
+	rules  []Rule
+	admins []string
+}
+
+// New returns a policy evaluating the rules. The given admins are allowed
+// every action, whatever the rules.
+func New(rules []Rule, admins []string) *Policy {
+	return &Policy{rules: rules, admins: admins}
+}
+
+// Evaluate allows the request when one of the rules applying to its action
+// does. The actions without rule are allowed, or restricted to the workspace
+// admins for the sensitive ones. The request is denied when a lookup needed to
+// decide fails and no other rule allows it.
+func (p *Policy) Evaluate(ctx context.Context, dir Directory, req Request) (Decision, error) {
+	if slices.Contains(p.admins, req.UserID) {
+		return Decision{Allowed: true, Rule: -1}, nil
+	}
+
+	var rules []Rule
+	var indexes []int
+	for i, rule := range p.rules {
+		if rule.appliesTo(req) {
+			rules = append(rules, rule)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(rules) == 0 {
+		if !restricted[req.Action] {
+			return Decision{Allowed: true, Rule: -1}, nil
+		}
+		rules, indexes = []Rule{{Action: req.Action, Admins: true}}, []int{-1}
+	}
+
+	var errs []error
+	for i, rule := range rules {
+		allowed, err := matches(ctx, dir, rule, req)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if allowed {
+			return Decision{Allowed: true, Rule: indexes[i]}, nil
+		}
+	}
+
+	return Decision{Rule: -1, Rules: rules}, errors.Join(errs...)
+}
+
+func matches(ctx context.Context, dir Directory, rule Rule, req Request) (bool, error) {
+	if len(rule.Channels) > 0 && !slices.Contains(rule.Channels, req.ChannelID) {
+		return false, nil
+	}
+	if !rule.hasPrincipals() || slices.Contains(rule.Users, req.UserID) {
+		return true, nil
+	}
+
+	var errs []error
+	for _, group := range rule.Usergroups {
+		members, err := dir.UsergroupMembers(ctx, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error fetching members of usergroup %s: %w", group, err))
+			continue
+		}
+		if slices.Contains(members, req.UserID) {
+			return true, nil
+		}
+	}
+	if rule.Admins {
+		admin, err := dir.IsAdmin(ctx, req.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error fetching user %s: %w", req.UserID, err))
+		} else if admin {
+			return true, nil
+		}
+	}
+	return false, errors.Join(errs...)
+}
+
+// UsergroupMention returns how a usergroup, given by ID or handle, is
+// mentioned in a Slack message.
+func UsergroupMention(usergroup string) string {
+	if isUsergroupID(usergroup) {
+		return fmt.Sprintf("<!subteam^%s>", usergroup)
+	}
+	return "@" + strings.TrimPrefix(usergroup, "@")
+}
+
+// isUsergroupID tells the usergroup IDs, e.g. S0123ABCD, from the handles.
+func isUsergroupID(usergroup string) bool {
+	if len(usergroup) < 9 || usergroup[0] != 'S' {
+		return false
+	}
+	for _, c := range usergroup[1:] {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesUsergroup reports whether a usergroup, given by ID or handle with or
+// without "@", is the one with the given ID and handle.
+func MatchesUsergroup(usergroup, id, handle string) bool {
+	if isUsergroupID(usergroup) {
+		return usergroup == id
+	}
+	return strings.EqualFold(strings.TrimPrefix(usergroup, "@"), handle)
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeDirectory struct {
+	admins     []string
+	usergroups map[string][]string
+	err        error
+}
+
+func (d fakeDirectory) IsAdmin(_ context.Context, userID string) (bool, error) {
+	if d.err != nil {
+		return false, d.err
+	}
+	for _, admin := range d.admins {
+		if admin == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d fakeDirectory) UsergroupMembers(_ context.Context, usergroup string) ([]string, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.usergroups[usergroup], nil
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	dir := fakeDirectory{
+		admins:     []string{"U-ADMIN"},
+		usergroups: map[string][]string{"@sre": {"U-SRE"}, "S0123ABCD": {"U-OPS"}},
+	}
+	policy := New([]Rule{
+		{Action: ActionConfig, Usergroups: []string{"@sre", "S0123ABCD"}},
+		{Action: ActionConfig, Team: "T1", Users: []string{"U-LEAD"}, Channels: []string{"C-OPS"}},
+		{Action: ActionRefresh, Channels: []string{"C-OPS"}},
+	}, []string{"U-ROOT"})
+
+	tests := []struct {
+		name    string
+		req     Request
+		allowed bool
+		rule    int
+	}{
+		{"server admin", Request{UserID: "U-ROOT", Action: ActionConfig}, true, -1},
+		{"usergroup handle", Request{UserID: "U-SRE", Action: ActionConfig}, true, 0},
+		{"usergroup ID", Request{UserID: "U-OPS", Action: ActionConfig}, true, 0},
+		{"user in channel", Request{TeamID: "T1", ChannelID: "C-OPS", UserID: "U-LEAD", Action: ActionConfig}, true, 1},
+		{"user in other channel", Request{TeamID: "T1", ChannelID: "C-GENERAL", UserID: "U-LEAD", Action: ActionConfig}, false, -1},
+		{"user in other team", Request{TeamID: "T2", ChannelID: "C-OPS", UserID: "U-LEAD", Action: ActionConfig}, false, -1},
+		{"workspace admin replaced by rules", Request{UserID: "U-ADMIN", Action: ActionConfig}, false, -1},
+		{"channel rule", Request{ChannelID: "C-OPS", UserID: "U-ANYONE", Action: ActionRefresh}, true, 2},
+		{"channel rule elsewhere", Request{ChannelID: "C-GENERAL", UserID: "U-ANYONE", Action: ActionRefresh}, false, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := policy.Evaluate(t.Context(), dir, tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decision.Allowed != tt.allowed || decision.Rule != tt.rule {
+				t.Errorf("expected allowed=%v rule=%d, got %+v", tt.allowed, tt.rule, decision)
+			}
+		})
+	}
+}
+
+func TestPolicy_Defaults(t *testing.T) {
+	dir := fakeDirectory{admins: []string{"U-ADMIN"}}
+	policy := New(nil, nil)
+
+	decision, _ := policy.Evaluate(t.Context(), dir, Request{UserID: "U-ANYONE", Action: ActionRefresh})
+	if !decision.Allowed {
+		t.Error("expected the actions without rule to be allowed")
+	}
+
+	decision, _ = policy.Evaluate(t.Context(), dir, Request{UserID: "U-ANYONE", Action: ActionConfig})
+	if decision.Allowed || len(decision.Rules) != 1 || !decision.Rules[0].Admins {
+		t.Errorf("expected config to be restricted to the workspace admins, got %+v", decision)
+	}
+
+	decision, _ = policy.Evaluate(t.Context(), dir, Request{UserID: "U-ADMIN", Action: ActionConfig})
+	if !decision.Allowed {
+		t.Error("expected workspace admins to change the settings")
+	}
+}
+
+func TestPolicy_LookupErrorDenies(t *testing.T) {
+	policy := New([]Rule{{Action: AnyAction, Usergroups: []string{"@sre"}}}, nil)
+
+	decision, err := policy.Evaluate(t.Context(), fakeDirectory{err: errors.New("ratelimited")}, Request{UserID: "U-SRE", Action: ActionRefresh})
+	if err == nil {
+		t.Error("expected the lookup error to be returned")
+	}
+	if decision.Allowed {
+		t.Error("expected the request to be denied")
+	}
+}
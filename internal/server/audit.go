@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/slack-go/slack"
+)
+
+const (
+	defaultAuditEntries = 10
+	maxAuditEntries     = 50
+)
+
+// userMention is how Slack escapes a user mention in a slash command, e.g.
+// <@U0123|jane>.
+var userMention = regexp.MustCompile(`^<@([^|>]+)(\|[^>]*)?>$`)
+
+type auditKey struct{}
+
+// auditDetails are what the handlers learn about the action while running it.
+type auditDetails struct {
+	schedules []string
+	err       string
+}
+
+// auditSchedules records the schedules shown or changed by the slash command
+// being handled.
+func auditSchedules(ctx context.Context, schedules []string) {
+	if d, ok := ctx.Value(auditKey{}).(*auditDetails); ok {
+		d.schedules = schedules
+	}
+}
+
+// auditError records the error the slash command being handled failed with.
+func auditError(ctx context.Context, err error) {
+	if d, ok := ctx.Value(auditKey{}).(*auditDetails); ok {
+		d.err = err.Error()
+	}
+}
+
+// withAudit records every slash command in the audit log once handled, with
+// the outcome set by the handlers. It must run within observeCommand.
+func (s *Server) withAudit(next http.Handler) http.Handler {
+	if s.audit == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := audit.Entry{
+			TeamID:    r.FormValue("team_id"),
+			ChannelID: r.FormValue("channel_id"),
+			UserID:    r.FormValue("user_id"),
+			Command:   r.FormValue("command"),
+			Action:    s.auditAction(r.FormValue("command"), r.FormValue("text")),
+			Args:      strings.TrimSpace(r.FormValue("text")),
+		}
+		details := &auditDetails{}
+		ctx := context.WithValue(r.Context(), auditKey{}, details)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		entry.Result = outcomeOK
+		if o, ok := r.Context().Value(outcomeKey{}).(*commandOutcome); ok {
+			entry.Result = o.value
+		}
+		if sw.status >= http.StatusBadRequest && entry.Result == outcomeOK {
+			entry.Result = outcomeError
+		}
+		entry.Schedules = details.schedules
+		entry.Error = details.err
+		s.audit.Record(ctx, entry)
+	})
+}
+
+// handleAudit serves `/oncall audit ...`, listing the recent entries of the
+// workspace.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request, cmd slack.SlashCommand) {
+	l := localizerFromContext(r.Context())
+	if s.audit == nil {
+		writeEphemeral(w, l.Text(slackmsg.MsgAuditDisabled))
+		return
+	}
+	if !s.authorize(w, r, cmd, policy.ActionAudit) {
+		return
+	}
+
+	query := audit.Query{TeamID: cmd.TeamID, Limit: defaultAuditEntries}
+	args := strings.Fields(cmd.Text)[1:]
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			query.Limit = min(n, maxAuditEntries)
+			continue
+		}
+		if m := userMention.FindStringSubmatch(arg); m != nil {
+			query.UserID = m[1]
+			continue
+		}
+		if action := s.auditAction(cmd.Command, arg); action != "other" {
+			query.Action = action
+			continue
+		}
+		writeEphemeral(w, l.Text(slackmsg.MsgAuditUsage))
+		return
+	}
+
+	entries := s.audit.Recent(query)
+	if len(entries) == 0 {
+		writeEphemeral(w, l.Text(slackmsg.MsgAuditEmpty))
+		return
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, formatAuditEntry(l, entry))
+	}
+	writeEphemeral(w, strings.Join(lines, "\n"))
+}
+
+// auditAction names the subcommand in the audit log, "oncall" for the
+// command alone.
+func (s *Server) auditAction(command, text string) string {
+	if strings.EqualFold(strings.TrimSpace(text), "oncall") {
+		return "oncall"
+	}
+	action := s.commands.subcommand(command, text)
+	if action == "" {
+		return "oncall"
+	}
+	return action
+}
+
+func formatAuditEntry(l slackmsg.Localizer, entry audit.Entry) string {
+	command := strings.TrimSpace(entry.Command + " " + entry.Args)
+	// Slack shows the date in the timezone of the reader, the fallback is
+	// in the one of the user
+	date := fmt.Sprintf(
+		"<!date^%d^{date_short_pretty} {time}|%s>",
+		entry.Time.Unix(), entry.Time.In(l.Location()).Format("2006-01-02 15:04 MST"),
+	)
+	line := l.Text(slackmsg.MsgAuditEntry, date, entry.UserID, command, entry.ChannelID, entry.Result)
+	if len(entry.Schedules) > 0 {
+		line += " (" + strings.Join(entry.Schedules, ", ") + ")"
+	}
+	return line
+}
@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
+)
+
+func TestAudit_RecordsCommands(t *testing.T) {
+	auditLog := audit.NewLog()
+	handler := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithAdmins([]string{mockAdminID}),
+		server.WithAuditLog(auditLog),
+	).Handler()
+
+	sendSlashCommand(handler, mockAdminID, mockChannel, "config set schedules=Platform")
+	sendSlashCommand(handler, "U-OTHER", mockChannel, "config unset schedules")
+	sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+
+	entries := auditLog.Recent(audit.Query{TeamID: mockTeamID})
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if e := entries[2]; e.UserID != mockAdminID || e.Action != "config" || e.Result != "ok" || e.Schedules[0] != "Platform" {
+		t.Errorf("unexpected entry for the settings change: %+v", e)
+	}
+	if e := entries[1]; e.UserID != "U-OTHER" || e.Result != "denied" {
+		t.Errorf("unexpected entry for the denied change: %+v", e)
+	}
+	if e := entries[0]; e.Action != "oncall" || len(e.Schedules) != 1 || e.Schedules[0] != "Platform" {
+		t.Errorf("unexpected entry for the schedules shown: %+v", e)
+	}
+
+	body := sendSlashCommand(handler, "U-OTHER", mockChannel, "audit")
+	if !strings.Contains(body, "Only workspace admins can read the audit log.") {
+		t.Errorf("expected the audit log to be restricted, got: %s", body)
+	}
+
+	var msg slack.Msg
+	body = sendSlashCommand(handler, mockAdminID, mockChannel, "audit 10 <@U-OTHER|other> config")
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatalf("expected a Slack message, got %s", body)
+	}
+	if !strings.HasSuffix(msg.Text, "<@U-OTHER> `/oncall config unset schedules` in <#C1>: denied") {
+		t.Errorf("expected the denied change of U-OTHER only, got: %s", msg.Text)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/slack-go/slack"
+)
+
+// actionVerbs complete the denial messages, "Only ... can <verb>."
+var actionVerbs = map[string]slackmsg.MessageKey{
+	policy.ActionConfig:  slackmsg.MsgActionConfig,
+	policy.ActionRefresh: slackmsg.MsgActionRefresh,
+	policy.ActionAudit:   slackmsg.MsgActionAudit,
+}
+
+// authorize evaluates the policy before running a privileged subcommand, and
+// answers with a denial message when the user may not run it.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, cmd slack.SlashCommand, action string) bool {
+	req := policy.Request{TeamID: cmd.TeamID, ChannelID: cmd.ChannelID, UserID: cmd.UserID, Action: action}
+	denial, allowed := s.evaluate(r.Context(), localizerFromContext(r.Context()), req)
+	if !allowed {
+		setOutcome(r.Context(), outcomeDenied)
+		writeEphemeral(w, denial)
+	}
+	return allowed
+}
+
+// evaluate returns whether the policy allows the request, or the message
+// explaining who may run the action. Every decision is logged for auditing.
+func (s *Server) evaluate(ctx context.Context, l slackmsg.Localizer, req policy.Request) (string, bool) {
+	s.fileSettingsMu.RLock()
+	rules := s.fileSettings.Policies
+	s.fileSettingsMu.RUnlock()
+
+	decision, err := policy.New(rules, s.admins).Evaluate(ctx, s.directory(ctx), req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error evaluating the authorization policy", "action", req.Action, "error", err)
+	}
+
+	attrs := []any{
+		"action", req.Action,
+		"teamID", req.TeamID,
+		"channelID", req.ChannelID,
+		"userID", req.UserID,
+		"rule", decision.Rule,
+	}
+	if decision.Allowed {
+		slog.InfoContext(ctx, "Authorization granted", attrs...)
+		return "", true
+	}
+
+	slog.WarnContext(ctx, "Authorization denied", attrs...)
+	return l.Text(slackmsg.MsgDenied, describeRules(l, decision.Rules), l.Text(actionVerbs[req.Action])), false
+}
+
+// describeRules tells who the rules allow, e.g. "members of @sre or
+// workspace admins in #ops".
+func describeRules(l slackmsg.Localizer, rules []policy.Rule) string {
+	descriptions := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		var principals []string
+		if rule.Admins {
+			principals = append(principals, l.Text(slackmsg.MsgWorkspaceAdmins))
+		}
+		for _, group := range rule.Usergroups {
+			principals = append(principals, l.Text(slackmsg.MsgUsergroupMembers, policy.UsergroupMention(group)))
+		}
+		for _, user := range rule.Users {
+			principals = append(principals, fmt.Sprintf("<@%s>", user))
+		}
+
+		who := l.Text(slackmsg.MsgEveryone)
+		if len(principals) > 0 {
+			who = strings.Join(principals, ", ")
+		}
+		if len(rule.Channels) > 0 {
+			channels := make([]string, len(rule.Channels))
+			for i, channel := range rule.Channels {
+				channels[i] = fmt.Sprintf("<#%s>", channel)
+			}
+			who = l.Text(slackmsg.MsgInChannels, who, strings.Join(channels, ", "))
+		}
+		descriptions = append(descriptions, who)
+	}
+	if len(descriptions) == 0 {
+		return l.Text(slackmsg.MsgServerAdmins)
+	}
+	return strings.Join(descriptions, " "+l.Text(slackmsg.MsgOr)+" ")
+}
+
+// directory looks up the users and usergroups with the bot token of the
+// installation. Without installation, nobody is an admin or in a usergroup.
+func (s *Server) directory(ctx context.Context) policy.Directory {
+	installation, ok := installationFromContext(ctx)
+	if !ok || installation.BotToken == "" {
+		return noDirectory{}
+	}
+	return &slackDirectory{
+		client: slack.New(installation.BotToken, slack.OptionHTTPClient(s.slackClient)),
+	}
+}
+
+type noDirectory struct{}
+
+func (noDirectory) IsAdmin(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (noDirectory) UsergroupMembers(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+// slackDirectory is a policy.Directory calling the Slack API. It lives for a
+// single request, the usergroups are listed once.
+type slackDirectory struct {
+	client     *slack.Client
+	usergroups []slack.UserGroup
+}
+
+// IsAdmin reports whether the user is one of the workspace admins or owners.
+func (d *slackDirectory) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	user, err := d.client.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("client.GetUserInfoContext: %w", err)
+	}
+	return user.IsAdmin || user.IsOwner || user.IsPrimaryOwner, nil
+}
+
+// UsergroupMembers returns the user IDs of the members of a usergroup, given by
+// ID or handle.
+func (d *slackDirectory) UsergroupMembers(ctx context.Context, usergroup string) ([]string, error) {
+	if d.usergroups == nil {
+		groups, err := d.client.GetUserGroupsContext(ctx, slack.GetUserGroupsOptionIncludeUsers(true))
+		if err != nil {
+			return nil, fmt.Errorf("client.GetUserGroupsContext: %w", err)
+		}
+		d.usergroups = groups
+	}
+
+	for _, group := range d.usergroups {
+		if policy.MatchesUsergroup(usergroup, group.ID, group.Handle) {
+			return group.Users, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+)
+
+func TestDescribeRules(t *testing.T) {
+	rules := []policy.Rule{
+		{Admins: true, Usergroups: []string{"sre", "S0123ABCD"}, Users: []string{"U1"}, Channels: []string{"C1"}},
+		{Channels: []string{"C2"}},
+	}
+
+	expected := "workspace admins, members of @sre, members of <!subteam^S0123ABCD>, <@U1> in <#C1> or everyone in <#C2>"
+	if got := describeRules(slackmsg.NewLocalizer("en", ""), rules); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/slack-go/slack"
+)
+
+// FileSettings holds the settings read from the configuration file. They are
+// replaced as a whole when the file is reloaded.
+type FileSettings struct {
+	// Aliases maps a name usable in place of schedule names to the schedules
+	// it stands for.
+	Aliases map[string][]string
+	// Channels holds the channel and workspace defaults. The ones set with
+	// `/oncall config` take precedence.
+	Channels []store.ChannelSettings
+	// Policies restrict the privileged subcommands to some users, usergroups
+	// or channels.
+	Policies []policy.Rule
+}
+
+// ReloadFileSettings replaces the settings read from the configuration file.
+func (s *Server) ReloadFileSettings(settings FileSettings) {
+	s.fileSettingsMu.Lock()
+	defer s.fileSettingsMu.Unlock()
+
+	s.fileSettings = settings
+}
+
+// defaultFilter returns the schedules configured for the channel, falling back
+// to the workspace defaults.
+func (s *Server) defaultFilter(teamID, channelID string) app.ScheduleFilter {
+	for _, id := range []string{channelID, ""} {
+		if names := s.storedSchedules(teamID, id); len(names) > 0 {
+			return app.ScheduleFilter{Names: s.expandAliases(names)}
+		}
+		if names := s.fileSchedules(teamID, id); len(names) > 0 {
+			return app.ScheduleFilter{Names: s.expandAliases(names)}
+		}
+	}
+
+	return app.ScheduleFilter{}
+}
+
+// defaultPublic reports whether `/oncall` answers publicly in the channel,
+// falling back to the workspace defaults.
+func (s *Server) defaultPublic(teamID, channelID string) bool {
+	for _, id := range []string{channelID, ""} {
+		if visibility := s.storedSettings(teamID, id).Visibility; visibility != "" {
+			return visibility == store.VisibilityPublic
+		}
+		if visibility := s.fileChannelSettings(teamID, id).Visibility; visibility != "" {
+			return visibility == store.VisibilityPublic
+		}
+	}
+
+	return false
+}
+
+func (s *Server) storedSchedules(teamID, channelID string) []string {
+	return s.storedSettings(teamID, channelID).Schedules
+}
+
+func (s *Server) fileSchedules(teamID, channelID string) []string {
+	return s.fileChannelSettings(teamID, channelID).Schedules
+}
+
+func (s *Server) storedSettings(teamID, channelID string) store.ChannelSettings {
+	if s.settings == nil {
+		return store.ChannelSettings{}
+	}
+
+	settings, err := s.settings.GetSettings(teamID, channelID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Error fetching settings", "teamID", teamID, "channelID", channelID, "error", err)
+		}
+		return store.ChannelSettings{}
+	}
+	return settings
+}
+
+func (s *Server) fileChannelSettings(teamID, channelID string) store.ChannelSettings {
+	s.fileSettingsMu.RLock()
+	defer s.fileSettingsMu.RUnlock()
+
+	for _, settings := range s.fileSettings.Channels {
+		if settings.TeamID == teamID && settings.ChannelID == channelID {
+			return settings
+		}
+	}
+	return store.ChannelSettings{}
+}
+
+// lookupAlias returns the schedules an alias stands for.
+func (s *Server) lookupAlias(name string) ([]string, bool) {
+	s.fileSettingsMu.RLock()
+	defer s.fileSettingsMu.RUnlock()
+
+	for alias, schedules := range s.fileSettings.Aliases {
+		if strings.EqualFold(alias, name) {
+			return schedules, true
+		}
+	}
+	return nil, false
+}
+
+// expandAliases replaces the aliases with the schedules they stand for.
+func (s *Server) expandAliases(names []string) []string {
+	expanded := make([]string, 0, len(names))
+	for _, name := range names {
+		if schedules, ok := s.lookupAlias(name); ok {
+			expanded = append(expanded, schedules...)
+			continue
+		}
+		expanded = append(expanded, name)
+	}
+	return expanded
+}
+
+// handleConfig serves `/oncall config ...`.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request, cmd slack.SlashCommand) {
+	l := localizerFromContext(r.Context())
+	if s.settings == nil {
+		writeEphemeral(w, l.Text(slackmsg.MsgConfigDisabled))
+		return
+	}
+
+	_, rest := cutWord(cmd.Text)
+	action, rest := cutWord(rest)
+
+	switch action {
+	case "show":
+		writeEphemeral(w, s.describeSettings(l, cmd.TeamID, cmd.ChannelID))
+		return
+	case "set", "unset":
+	default:
+		writeEphemeral(w, l.Text(slackmsg.MsgConfigUsage))
+		return
+	}
+
+	if !s.authorize(w, r, cmd, policy.ActionConfig) {
+		return
+	}
+
+	channelID, scope := cmd.ChannelID, l.Text(slackmsg.MsgThisChannel)
+	if word, afterScope := cutWord(rest); word == "workspace" {
+		channelID, scope, rest = "", l.Text(slackmsg.MsgThisWorkspace), afterScope
+	}
+
+	settings, err := s.settings.GetSettings(cmd.TeamID, channelID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Error fetching settings", "teamID", cmd.TeamID, "channelID", channelID, "error", err)
+		setOutcome(r.Context(), outcomeError)
+		writeEphemeral(w, l.Text(slackmsg.MsgError))
+		return
+	}
+	settings.TeamID = cmd.TeamID
+	settings.ChannelID = channelID
+	settings.UpdatedBy = cmd.UserID
+	settings.UpdatedAt = time.Now().UTC()
+
+	var reply string
+	switch key, value, _ := strings.Cut(rest, "="); {
+	case action == "set" && strings.TrimSpace(key) == "visibility":
+		visibility := strings.ToLower(strings.TrimSpace(value))
+		if visibility != store.VisibilityPublic && visibility != store.VisibilityPrivate {
+			writeEphemeral(w, l.Text(slackmsg.MsgConfigUsage))
+			return
+		}
+		settings.Visibility = visibility
+	case action == "set" && strings.TrimSpace(key) == "schedules":
+		names, unknown, err := s.resolveScheduleNames(r.Context(), value)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error fetching schedules", "error", err)
+			setOutcome(r.Context(), outcomeError)
+			auditError(r.Context(), err)
+			writeEphemeral(w, l.Text(slackmsg.MsgError))
+			return
+		}
+		if len(unknown) > 0 {
+			writeEphemeral(w, l.Text(slackmsg.MsgUnknownSchedules, strings.Join(unknown, ", ")))
+			return
+		}
+		if len(names) == 0 {
+			writeEphemeral(w, l.Text(slackmsg.MsgConfigUsage))
+			return
+		}
+		settings.Schedules = names
+		reply = l.Text(slackmsg.MsgSchedulesSet, strings.Join(names, ", "), scope)
+	case action == "unset" && rest == "visibility":
+		settings.Visibility = ""
+	case action == "unset" && rest == "schedules":
+		settings.Schedules = nil
+		reply = l.Text(slackmsg.MsgSchedulesUnset, scope)
+	default:
+		writeEphemeral(w, l.Text(slackmsg.MsgConfigUsage))
+		return
+	}
+
+	if settings.IsEmpty() {
+		err = s.settings.DeleteSettings(cmd.TeamID, channelID)
+	} else {
+		err = s.settings.SaveSettings(settings)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving settings", "teamID", cmd.TeamID, "channelID", channelID, "error", err)
+		setOutcome(r.Context(), outcomeError)
+		auditError(r.Context(), err)
+		writeEphemeral(w, l.Text(slackmsg.MsgError))
+		return
+	}
+	if reply == "" {
+		// The visibility changed, the one now applying may come from the
+		// workspace or the configuration file
+		reply = l.Text(slackmsg.MsgVisibilitySet, describeVisibility(l, s.defaultPublic(cmd.TeamID, channelID)), scope)
+	}
+
+	auditSchedules(r.Context(), settings.Schedules)
+	slog.InfoContext(
+		r.Context(),
+		"Settings updated",
+		"teamID", cmd.TeamID,
+		"channelID", channelID,
+		"userID", cmd.UserID,
+		"schedules", settings.Schedules,
+		"visibility", settings.Visibility,
+	)
+	writeEphemeral(w, reply)
+}
+
+// resolveScheduleNames splits a comma separated list of schedule names and
+// maps them to the names known by Compass. Aliases are kept as is so changes
+// to the configuration file apply to the stored settings.
+func (s *Server) resolveScheduleNames(ctx context.Context, value string) ([]string, []string, error) {
+	known, err := s.app.GetScheduleNames(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names, unknown []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := s.lookupAlias(name); ok {
+			names = append(names, name)
+			continue
+		}
+		idx := slices.IndexFunc(known, func(k string) bool { return strings.EqualFold(k, name) })
+		if idx < 0 {
+			unknown = append(unknown, name)
+			continue
+		}
+		names = append(names, known[idx])
+	}
+	return names, unknown, nil
+}
+
+func (s *Server) describeSettings(l slackmsg.Localizer, teamID, channelID string) string {
+	var lines []string
+	for _, target := range []struct {
+		id    string
+		scope slackmsg.MessageKey
+	}{{channelID, slackmsg.MsgChannelSettings}, {"", slackmsg.MsgWorkspaceSettings}} {
+		names := s.storedSchedules(teamID, target.id)
+		if len(names) == 0 {
+			names = s.fileSchedules(teamID, target.id)
+		}
+		schedules := l.Text(slackmsg.MsgEverySchedule)
+		if len(names) > 0 {
+			schedules = strings.Join(names, ", ")
+		}
+		line := l.Text(target.scope, schedules)
+
+		visibility := s.storedSettings(teamID, target.id).Visibility
+		if visibility == "" {
+			visibility = s.fileChannelSettings(teamID, target.id).Visibility
+		}
+		if visibility != "" {
+			line = l.Text(slackmsg.MsgAnswered, line, describeVisibility(l, visibility == store.VisibilityPublic))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func describeVisibility(l slackmsg.Localizer, public bool) string {
+	if public {
+		return l.Text(slackmsg.MsgVisibilityPublic)
+	}
+	return l.Text(slackmsg.MsgVisibilityPrivate)
+}
+
+// cutWord splits the first word from the rest of the text.
+func cutWord(text string) (string, string) {
+	word, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	return word, strings.TrimSpace(rest)
+}
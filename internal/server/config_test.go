@@ -0,0 +1,374 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/metrics"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
+)
+
+const (
+	mockAdminID = "U-ADMIN"
+	mockTeamID  = "T1"
+	mockChannel = "C1"
+)
+
+func givenMultiScheduleCompassClient() *api.CompassClient {
+	mockCompassClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: io.NopCloser(strings.NewReader(`{"values": [
+						{"id": "schedule-1", "name": "Platform"},
+						{"id": "schedule-2", "name": "Payments"}
+					]}`)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`)),
+			}, nil
+		}),
+	}
+
+	return api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithHttpClient(mockCompassClient))
+}
+
+func givenSettingsServer(settings store.SettingsStore) http.Handler {
+	return server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithSettingsStore(settings),
+		server.WithAdmins([]string{mockAdminID}),
+	).Handler()
+}
+
+var triggerIDs atomic.Int64
+
+func sendSlashCommand(handler http.Handler, userID, channelID, text string) string {
+	body := url.Values{
+		"command":    {"/oncall"},
+		"team_id":    {mockTeamID},
+		"channel_id": {channelID},
+		"user_id":    {userID},
+		"text":       {text},
+		// Unique to every command, like in Slack, so identical commands are
+		// not rejected as replays
+		"trigger_id": {strconv.FormatInt(triggerIDs.Add(1), 10)},
+	}.Encode()
+	req := utils.CreateValidSlackRequest(mockSigningSecret, body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Body.String()
+}
+
+func TestConfig_ChannelDefaults(t *testing.T) {
+	settings := store.NewMemorySettingsStore()
+	handler := givenSettingsServer(settings)
+
+	body := sendSlashCommand(handler, mockAdminID, mockChannel, "config set schedules=platform")
+	if !strings.Contains(body, "now shows Platform by default in this channel") {
+		t.Fatalf("expected confirmation, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+	if !strings.Contains(body, "*Platform*") || strings.Contains(body, "*Payments*") {
+		t.Errorf("expected only the Platform schedule, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, "U-OTHER", mockChannel, "all")
+	if !strings.Contains(body, "*Platform*") || !strings.Contains(body, "*Payments*") {
+		t.Errorf("expected every schedule with 'all', got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, "U-OTHER", "C2", "")
+	if !strings.Contains(body, "*Payments*") {
+		t.Errorf("expected other channels to be unaffected, got: %s", body)
+	}
+}
+
+func TestConfig_WorkspaceDefaults(t *testing.T) {
+	settings := store.NewMemorySettingsStore()
+	handler := givenSettingsServer(settings)
+
+	sendSlashCommand(handler, mockAdminID, mockChannel, "config set workspace schedules=Payments")
+	sendSlashCommand(handler, mockAdminID, mockChannel, "config set schedules=Platform")
+
+	body := sendSlashCommand(handler, "U-OTHER", "C2", "")
+	if !strings.Contains(body, "*Payments*") || strings.Contains(body, "*Platform*") {
+		t.Errorf("expected the workspace default, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+	if !strings.Contains(body, "*Platform*") || strings.Contains(body, "*Payments*") {
+		t.Errorf("expected the channel default to win, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, mockAdminID, mockChannel, "config unset schedules")
+	if !strings.Contains(body, "every schedule") {
+		t.Fatalf("expected confirmation, got: %s", body)
+	}
+	body = sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+	if !strings.Contains(body, "*Payments*") || strings.Contains(body, "*Platform*") {
+		t.Errorf("expected the workspace default after unset, got: %s", body)
+	}
+}
+
+func TestConfig_RequiresAdmin(t *testing.T) {
+	settings := store.NewMemorySettingsStore()
+	handler := givenSettingsServer(settings)
+
+	body := sendSlashCommand(handler, "U-OTHER", mockChannel, "config set schedules=Platform")
+	if !strings.Contains(body, "Only workspace admins") {
+		t.Errorf("expected permission error, got: %s", body)
+	}
+	if _, err := settings.GetSettings(mockTeamID, mockChannel); err == nil {
+		t.Error("expected settings not to be saved")
+	}
+}
+
+func TestConfig_WorkspaceAdminFromSlack(t *testing.T) {
+	slackClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "users.info") {
+				t.Errorf("unexpected Slack API call to %s", req.URL.Path)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"ok": true, "user": {"id": "U-OWNER", "is_admin": true}}`)),
+			}, nil
+		}),
+	}
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: mockTeamID, BotToken: "xoxb-1"})
+	handler := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithTokenStore(tokens),
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithSlackHttpClient(slackClient),
+	).Handler()
+
+	body := sendSlashCommand(handler, "U-OWNER", mockChannel, "config set schedules=Platform")
+	if !strings.Contains(body, "now shows Platform") {
+		t.Errorf("expected workspace admin to change the settings, got: %s", body)
+	}
+}
+
+// visibility returns the response type of the answer to a slash command, and
+// whether it has a share button.
+func visibility(t *testing.T, body string) (string, bool) {
+	t.Helper()
+
+	var msg slack.Msg
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatalf("expected a JSON message, got: %s", body)
+	}
+	return msg.ResponseType, strings.Contains(body, slackmsg.ActionIDShare)
+}
+
+func TestConfig_Visibility(t *testing.T) {
+	handler := givenSettingsServer(store.NewMemorySettingsStore())
+
+	for _, step := range []struct {
+		userID, text string
+		responseType string
+		share        bool
+		reply        string
+	}{
+		{"U1", "", slack.ResponseTypeEphemeral, true, ""},
+		{"U1", "public all", slack.ResponseTypeInChannel, false, ""},
+		{mockAdminID, "config set visibility=public", "", false, "now answers in the channel by default in this channel"},
+		{"U1", "", slack.ResponseTypeInChannel, false, ""},
+		{"U1", "private", slack.ResponseTypeEphemeral, true, ""},
+		{"U1", "config show", "", false, "This channel: every schedule, answered in the channel"},
+		{mockAdminID, "config unset visibility", "", false, "now answers only to the user running it by default in this channel"},
+		{"U1", "", slack.ResponseTypeEphemeral, true, ""},
+	} {
+		body := sendSlashCommand(handler, step.userID, mockChannel, step.text)
+		if step.reply != "" {
+			if !strings.Contains(body, step.reply) {
+				t.Errorf("expected %q to reply %q, got: %s", step.text, step.reply, body)
+			}
+			continue
+		}
+		if responseType, share := visibility(t, body); responseType != step.responseType || share != step.share {
+			t.Errorf("expected %q to answer %s with share button %v, got %s and %v", step.text, step.responseType, step.share, responseType, share)
+		}
+	}
+}
+
+func TestConfig_FileVisibility(t *testing.T) {
+	handler := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithFileSettings(server.FileSettings{
+			Channels: []store.ChannelSettings{{TeamID: mockTeamID, Visibility: store.VisibilityPublic}},
+		}),
+	).Handler()
+
+	if responseType, _ := visibility(t, sendSlashCommand(handler, "U1", mockChannel, "")); responseType != slack.ResponseTypeInChannel {
+		t.Errorf("expected the workspace default from the file, got %s", responseType)
+	}
+}
+
+func TestConfig_UnknownSchedule(t *testing.T) {
+	handler := givenSettingsServer(store.NewMemorySettingsStore())
+
+	body := sendSlashCommand(handler, mockAdminID, mockChannel, "config set schedules=Platform, Nope")
+	if !strings.Contains(body, "Unknown schedules: Nope") {
+		t.Errorf("expected unknown schedule error, got: %s", body)
+	}
+}
+
+func TestConfig_FileSettings(t *testing.T) {
+	srv := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithAdmins([]string{mockAdminID}),
+		server.WithFileSettings(server.FileSettings{
+			Aliases: map[string][]string{"money": {"Payments"}},
+			Channels: []store.ChannelSettings{
+				{TeamID: mockTeamID, ChannelID: mockChannel, Schedules: []string{"money"}},
+			},
+		}),
+	)
+	handler := srv.Handler()
+
+	body := sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+	if !strings.Contains(body, "*Payments*") || strings.Contains(body, "*Platform*") {
+		t.Errorf("expected the alias from the file to be expanded, got: %s", body)
+	}
+
+	srv.ReloadFileSettings(server.FileSettings{
+		Aliases: map[string][]string{"money": {"Platform"}},
+		Channels: []store.ChannelSettings{
+			{TeamID: mockTeamID, ChannelID: mockChannel, Schedules: []string{"money"}},
+		},
+	})
+	body = sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+	if !strings.Contains(body, "*Platform*") || strings.Contains(body, "*Payments*") {
+		t.Errorf("expected the reloaded alias, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, mockAdminID, "C2", "config set schedules=MONEY")
+	if !strings.Contains(body, "now shows MONEY") {
+		t.Fatalf("expected aliases to be accepted, got: %s", body)
+	}
+	body = sendSlashCommand(handler, "U-OTHER", "C2", "")
+	if !strings.Contains(body, "*Platform*") || strings.Contains(body, "*Payments*") {
+		t.Errorf("expected the stored alias to be expanded, got: %s", body)
+	}
+}
+
+func TestConfig_CommandMetrics(t *testing.T) {
+	handler := givenSettingsServer(store.NewMemorySettingsStore())
+	sendSlashCommand(handler, "U-OTHER", mockChannel, "config set schedules=Platform")
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, expected := range []string{
+		`pompiers_slack_commands_total{command="/oncall config",outcome="denied"}`,
+		`pompiers_slack_request_duration_seconds_count{handler="command"}`,
+	} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected %s in the metrics", expected)
+		}
+	}
+}
+
+func TestConfig_UsergroupPolicy(t *testing.T) {
+	slackClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := `{"ok": true, "usergroups": [
+				{"id": "S0123ABCD", "handle": "sre", "users": ["U-SRE"]}
+			]}`
+			switch {
+			case strings.HasSuffix(req.URL.Path, "users.info"):
+				// The replies are localized
+				body = `{"ok": true, "user": {"locale": "en-US", "tz": "America/New_York"}}`
+			case !strings.HasSuffix(req.URL.Path, "usergroups.list"):
+				t.Errorf("unexpected Slack API call to %s", req.URL.Path)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		}),
+	}
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: mockTeamID, BotToken: "xoxb-1"})
+	handler := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithTokenStore(tokens),
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithSlackHttpClient(slackClient),
+		server.WithFileSettings(server.FileSettings{
+			Policies: []policy.Rule{{Action: policy.ActionConfig, Usergroups: []string{"@sre"}}},
+		}),
+	).Handler()
+
+	body := sendSlashCommand(handler, "U-OTHER", mockChannel, "config set schedules=Platform")
+	if !strings.Contains(body, "Only members of @sre can change the settings.") {
+		t.Errorf("expected the policy denial, got: %s", body)
+	}
+
+	body = sendSlashCommand(handler, "U-SRE", mockChannel, "config set schedules=Platform")
+	if !strings.Contains(body, "now shows Platform") {
+		t.Errorf("expected the usergroup member to change the settings, got: %s", body)
+	}
+}
+
+func TestOnCall_Routes(t *testing.T) {
+	handler := server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithAdmins([]string{mockAdminID}),
+		server.WithAuditLog(audit.NewLog()),
+	).Handler()
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "*Platform*"},
+		{"all", "*Payments*"},
+		{"refresh all", "*Payments*"},
+		{"config show", "This channel: every schedule"},
+		{"audit", "`/oncall all` in"},
+		{"help", "/oncall config show|set|unset ..."},
+		{"confg", "Did you mean `/oncall config`?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if body := sendSlashCommand(handler, mockAdminID, mockChannel, tt.text); !strings.Contains(body, tt.want) {
+				t.Errorf("expected %q in the response, got: %s", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/server"
+)
+
+// givenFakeAtlassian starts a stand-in serving both the Compass API (behind
+// the API gateway) and the Jira API (on the site).
+func givenFakeAtlassian(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	compassPrefix := "/compass/cloud/" + mockCloudID + "/ops/v1"
+	users := map[string]string{"user-1": "Alice", "user-2": "Bob"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+compassPrefix+"/schedules", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"values": []map[string]string{
+				{"id": "schedule-1", "name": "Platform"},
+				{"id": "schedule-2", "name": "Payments"},
+			},
+		})
+	})
+	mux.HandleFunc("GET "+compassPrefix+"/schedules/{id}/on-calls", func(w http.ResponseWriter, r *http.Request) {
+		participant := map[string]string{"schedule-1": "user-1", "schedule-2": "user-2"}[r.PathValue("id")]
+		fmt.Fprintf(w, `{"onCallParticipants": [{"id": "%s", "type": "user"}]}`, participant)
+	})
+	mux.HandleFunc("GET "+compassPrefix+"/schedules/{id}/timeline", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+		fmt.Fprintf(w, `{"finalTimeline": {"rotations": [{"id": "rotation-1", "periods": [{"startDate": "%s", "endDate": "%s", "type": "default"}]}]}}`,
+			now.Add(-time.Hour).Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339))
+	})
+	mux.HandleFunc("GET /rest/api/3/myself", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"accountId": "bot", "displayName": "Pompiers", "active": true}`)
+	})
+	mux.HandleFunc("GET /rest/api/3/user", func(w http.ResponseWriter, r *http.Request) {
+		accountID := r.URL.Query().Get("accountId")
+		name, ok := users[accountID]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type": "error", "error": {"message": "User does not exist"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"accountId": "%s", "displayName": "%s", "active": true}`, accountID, name)
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, key, ok := r.BasicAuth()
+		if !ok || user != mockUser || key != mockAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestEndToEnd_FakeAtlassian(t *testing.T) {
+	atlassian := givenFakeAtlassian(t)
+
+	app := app.NewApp(
+		api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithBaseUrl(atlassian.URL)),
+		newJiraClient(mockAPIKey, atlassian.URL),
+	)
+	port, err := utils.FindFreePort()
+	if err != nil {
+		t.Fatalf("Failed to get available port: %v", err)
+	}
+	srv := server.NewServer(app, "", port, mockSigningSecret)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Stop(t.Context())
+
+	body := url.Values{"command": {"/oncall"}, "team_id": {"T1"}, "user_id": {"U1"}}.Encode()
+	httpReq, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d/", port), strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	httpReq.Header = utils.GenerateValidSlackHeaders(mockSigningSecret, body)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	byteBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	response := string(byteBody)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d with body: %s", http.StatusOK, resp.StatusCode, response)
+	}
+	for _, expected := range []string{
+		`"text":"*Platform*\nUntil `,
+		`{"type":"mrkdwn","text":"Alice"}`,
+		`"text":"*Payments*\nUntil `,
+		`{"type":"mrkdwn","text":"Bob"}`,
+	} {
+		if !strings.Contains(response, expected) {
+			t.Errorf("Expected body to contain %s, got: %s", expected, response)
+		}
+	}
+}
+
+func TestEndToEnd_Refresher(t *testing.T) {
+	atlassian := givenFakeAtlassian(t)
+
+	var fetches atomic.Int32
+	countingClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/on-calls") {
+				fetches.Add(1)
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	oncall := app.NewApp(
+		api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithBaseUrl(atlassian.URL), api.WithHttpClient(countingClient)),
+		newJiraClient(mockAPIKey, atlassian.URL),
+	)
+	handler := server.NewServer(
+		oncall, "", 0, mockSigningSecret,
+		server.WithRefresher(app.NewRefresher(oncall, time.Minute)),
+	).Handler()
+
+	for _, text := range []string{"", "all"} {
+		body := sendSlashCommand(handler, "U1", mockChannel, text)
+		if !strings.Contains(body, `"text":"Alice"`) || !strings.Contains(body, `Updated \u003c!date^`) {
+			t.Errorf("expected the snapshot with its age, got: %s", body)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected the schedules to be fetched once, got %d calls", n)
+	}
+
+	sendSlashCommand(handler, "U1", mockChannel, "refresh")
+	if n := fetches.Load(); n != 4 {
+		t.Errorf("expected refresh to fetch the schedules again, got %d calls", n)
+	}
+}
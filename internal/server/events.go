@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/slack-go/slack/slackevents"
+)
+
+// handleEvents serves the Events API endpoint. The payload signature has
+// already been verified by the middleware.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing Slack event", "error", err)
+		http.Error(w, "Error parsing event", http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			http.Error(w, "Error parsing challenge", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge.Challenge))
+		return
+	case slackevents.CallbackEvent:
+		s.dispatchEvent(r.Context(), event)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) dispatchEvent(ctx context.Context, event slackevents.EventsAPIEvent) {
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppUninstalledEvent:
+		s.removeInstallation(ctx, event.TeamID, ev.Type)
+	case *slackevents.TokensRevokedEvent:
+		// Only the bot token is stored, revoked user tokens don't matter
+		if len(ev.Tokens.Bot) > 0 {
+			s.removeInstallation(ctx, event.TeamID, ev.Type)
+		}
+	}
+}
+
+func (s *Server) removeInstallation(ctx context.Context, teamID, reason string) {
+	if s.tokens == nil {
+		return
+	}
+	if err := s.tokens.Delete(teamID); err != nil {
+		slog.ErrorContext(ctx, "Error removing installation", "teamID", teamID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Slack workspace uninstalled", "teamID", teamID, "reason", reason)
+}
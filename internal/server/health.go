@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+
+	// readinessCacheTTL is how long the result of a dependency check is
+	// reused, so frequent probes don't hit the Atlassian APIs every time
+	readinessCacheTTL = 30 * time.Second
+)
+
+// cachedCheck runs a dependency check at most once per TTL. Concurrent
+// callers wait for the same check.
+type cachedCheck struct {
+	ttl   time.Duration
+	check func(ctx context.Context) error
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+func (c *cachedCheck) Run(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.err
+	}
+	// The result is shared, a probe giving up must not fail the next ones
+	c.err = c.check(context.WithoutCancel(ctx))
+	c.checkedAt = time.Now()
+	return c.err
+}
+
+// ProbeHandler serves the health and readiness endpoints alone, for listeners
+// other than the one of the server, which does not run in Socket Mode.
+func (s *Server) ProbeHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+healthzPath, s.handleHealthz)
+	mux.HandleFunc("GET "+readyzPath, s.handleReadyz)
+	return mux
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// handleHealthz reports that the process is up.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// handleReadyz reports whether the Atlassian credentials are valid and the
+// last snapshot refresh succeeded. The errors are logged, not returned, as the
+// endpoint is public.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := readinessResponse{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+
+	checks := map[string]func() error{
+		"atlassian": func() error { return s.atlassianCheck.Run(r.Context()) },
+	}
+	if s.refresher != nil {
+		checks["snapshot"] = s.refresher.Err
+	}
+
+	for name, check := range checks {
+		if err := check(); err != nil {
+			slog.WarnContext(r.Context(), "Readiness check failed", "check", name, "error", err)
+			response.Checks[name] = "failing"
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/server"
+)
+
+func getProbe(handler http.Handler, path string) (int, map[string]any) {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	return rr.Code, body
+}
+
+func TestHealthz(t *testing.T) {
+	handler := server.NewServer(nil, "", 0, mockSigningSecret).Handler()
+
+	status, body := getProbe(handler, "/healthz")
+	if status != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("expected an unsigned probe to succeed, got %v: %v", status, body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	atlassian := givenFakeAtlassian(t)
+
+	var checks atomic.Int32
+	countingClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/myself") {
+				checks.Add(1)
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	oncall := app.NewApp(
+		api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithBaseUrl(atlassian.URL)),
+		newJiraClient(mockAPIKey, atlassian.URL, api.WithJiraHttpClient(countingClient)),
+	)
+	refresher := app.NewRefresher(oncall, time.Minute)
+	handler := server.NewServer(oncall, "", 0, mockSigningSecret, server.WithRefresher(refresher)).Handler()
+
+	status, body := getProbe(handler, "/readyz")
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready before the first snapshot, got %v: %v", status, body)
+	}
+
+	if _, err := refresher.Refresh(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, body = getProbe(handler, "/readyz")
+	if status != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("expected ready, got %v: %v", status, body)
+	}
+
+	if n := checks.Load(); n != 1 {
+		t.Errorf("expected the credentials check to be cached, got %d checks", n)
+	}
+}
+
+func TestReadyz_InvalidCredentials(t *testing.T) {
+	atlassian := givenFakeAtlassian(t)
+
+	oncall := app.NewApp(
+		api.NewCompassClient(mockUser, "wrong-key", mockCloudID, api.WithBaseUrl(atlassian.URL)),
+		newJiraClient("wrong-key", atlassian.URL),
+	)
+	handler := server.NewServer(oncall, "", 0, mockSigningSecret).Handler()
+
+	status, body := getProbe(handler, "/readyz")
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("expected not ready, got %v: %v", status, body)
+	}
+	if checks, _ := body["checks"].(map[string]any); checks["atlassian"] != "failing" {
+		t.Errorf("expected the atlassian check to fail, got %v", body)
+	}
+}
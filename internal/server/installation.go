@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+)
+
+type installationKey struct{}
+
+// installationFromContext returns the installation of the workspace the
+// request originates from. It is only set when a token store is configured.
+func installationFromContext(ctx context.Context) (store.Installation, bool) {
+	installation, ok := ctx.Value(installationKey{}).(store.Installation)
+	return installation, ok
+}
+
+// withInstallation resolves the workspace credentials from the team_id of the
+// slash command or interactive payload and stores them in the request context.
+func (s *Server) withInstallation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		teamID := r.FormValue("team_id")
+		if payload := r.FormValue("payload"); payload != "" {
+			var interaction struct {
+				Team struct {
+					ID string `json:"id"`
+				} `json:"team"`
+			}
+			json.Unmarshal([]byte(payload), &interaction)
+			teamID = interaction.Team.ID
+		}
+		installation, err := s.tokens.Get(teamID)
+		if errors.Is(err, store.ErrNotFound) {
+			slog.WarnContext(r.Context(), "Request from a workspace without installation", "teamID", teamID)
+			setOutcome(r.Context(), outcomeNotInstalled)
+			// Without installation, the user can't be looked up
+			writeEphemeral(w, s.defaultLocalizer.Text(slackmsg.MsgNotInstalled))
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error fetching installation", "teamID", teamID, "error", err)
+			http.Error(w, "Error fetching installation", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), installationKey{}, installation)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
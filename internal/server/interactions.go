@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// interactionTimeout bounds the work done after an interaction or a slash
+	// command has been acknowledged
+	interactionTimeout = 30 * time.Second
+
+	// allSchedulesValue is the value of the buttons of `/oncall all`, the
+	// other messages only show the schedules of the channel
+	allSchedulesValue     = "all"
+	channelSchedulesValue = "channel"
+)
+
+// handleInteractions serves the interactivity endpoint. Slack expects the
+// interactions to be acknowledged within 3 seconds, so the buttons are acted
+// upon in the background and the messages updated through their response URL.
+func (s *Server) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &callback); err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing interaction payload", "error", err)
+		http.Error(w, "Error parsing interaction payload", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		var act func(context.Context, slack.InteractionCallback, string, *audit.Entry) []*slack.WebhookMessage
+		var name string
+		switch action.ActionID {
+		case slackmsg.ActionIDRefresh:
+			act, name = s.refreshMessage, policy.ActionRefresh
+		case slackmsg.ActionIDShare:
+			act, name = s.shareMessage, "share"
+		case slackmsg.ActionIDPreviousPage, slackmsg.ActionIDNextPage:
+			act, name = s.pageMessage, "page"
+		case slackmsg.ActionIDScheduleLink:
+			// The link is opened by Slack, there is nothing left to do
+			continue
+		default:
+			slog.WarnContext(r.Context(), "Unknown interaction", "actionID", action.ActionID)
+			continue
+		}
+
+		entry := audit.Entry{
+			TeamID:    callback.Team.ID,
+			ChannelID: callback.Channel.ID,
+			UserID:    callback.User.ID,
+			Command:   "button",
+			Action:    name,
+			Result:    outcomeOK,
+		}
+		// The request context ends with the acknowledgement, its values are
+		// kept for the logs and traces
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), interactionTimeout)
+		go func(value string) {
+			defer cancel()
+			msgs := act(ctx, callback, value, &entry)
+			if s.audit != nil {
+				s.audit.Record(ctx, entry)
+			}
+			s.respond(ctx, callback.ResponseURL, msgs...)
+		}(action.Value)
+	}
+}
+
+// refreshMessage fetches the schedules from Compass again, to replace the
+// message the button belongs to.
+func (s *Server) refreshMessage(ctx context.Context, callback slack.InteractionCallback, value string, entry *audit.Entry) []*slack.WebhookMessage {
+	req := policy.Request{TeamID: callback.Team.ID, ChannelID: callback.Channel.ID, UserID: callback.User.ID, Action: policy.ActionRefresh}
+	if denial, allowed := s.evaluate(ctx, s.localizer(ctx, callback.Team.ID, callback.User.ID), req); !allowed {
+		entry.Result = outcomeDenied
+		return []*slack.WebhookMessage{{Text: denial, ResponseType: slack.ResponseTypeEphemeral}}
+	}
+
+	return s.replaceMessage(ctx, callback, value, true, entry)
+}
+
+// pageMessage replaces the message the button belongs to with another page of
+// the schedules.
+func (s *Server) pageMessage(ctx context.Context, callback slack.InteractionCallback, value string, entry *audit.Entry) []*slack.WebhookMessage {
+	return s.replaceMessage(ctx, callback, value, false, entry)
+}
+
+// replaceMessage renders the page of the schedules the value of the button
+// points to, in place of the message. Pages with buttons fit in a single
+// message, so the message is replaced as a whole.
+func (s *Server) replaceMessage(ctx context.Context, callback slack.InteractionCallback, value string, force bool, entry *audit.Entry) []*slack.WebhookMessage {
+	schedules, page := slackmsg.ParseActionValue(value)
+	l := s.localizer(ctx, callback.Team.ID, callback.User.ID)
+	schedule, err := s.currentSchedule(ctx, s.interactionFilter(callback, schedules), force)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching current on-call schedule", "error", err)
+		entry.Result, entry.Error = outcomeError, err.Error()
+		return []*slack.WebhookMessage{{Text: errorText(l, err), ResponseType: slack.ResponseTypeEphemeral}}
+	}
+	entry.Schedules = scheduleNames(schedule.Schedules)
+
+	opts := []slackmsg.MessageOption{slackmsg.WithActions(schedules), slackmsg.WithPage(page), slackmsg.WithLocalizer(l)}
+	public := isPublic(callback)
+	if public {
+		opts = append(opts, slackmsg.InChannel())
+	}
+	msgs := webhookMessages(slackmsg.ToMessages(schedule, opts...), responseType(public))
+	msgs[0].ReplaceOriginal = true
+	return msgs
+}
+
+// shareMessage posts the page of the schedules shown to the user to the
+// channel, for everyone to see. The schedules come from the snapshot, which is
+// at least as fresh as the ones shown.
+func (s *Server) shareMessage(ctx context.Context, callback slack.InteractionCallback, value string, entry *audit.Entry) []*slack.WebhookMessage {
+	schedules, page := slackmsg.ParseActionValue(value)
+	l := s.localizer(ctx, callback.Team.ID, callback.User.ID)
+	schedule, err := s.currentSchedule(ctx, s.interactionFilter(callback, schedules), false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching current on-call schedule", "error", err)
+		entry.Result, entry.Error = outcomeError, err.Error()
+		return []*slack.WebhookMessage{{Text: errorText(l, err), ResponseType: slack.ResponseTypeEphemeral}}
+	}
+	entry.Schedules = scheduleNames(schedule.Schedules)
+
+	return webhookMessages(slackmsg.ToMessages(
+		schedule,
+		slackmsg.SharedBy(callback.User.ID),
+		slackmsg.WithPage(page),
+		slackmsg.WithLocalizer(l),
+	), slack.ResponseTypeInChannel)
+}
+
+// isPublic reports whether the button belongs to a message posted in the
+// channel.
+func isPublic(callback slack.InteractionCallback) bool {
+	return callback.Container.Type == "message" && !callback.Container.IsEphemeral
+}
+
+func (s *Server) interactionFilter(callback slack.InteractionCallback, value string) app.ScheduleFilter {
+	if value == allSchedulesValue {
+		return app.ScheduleFilter{}
+	}
+	return s.defaultFilter(callback.Team.ID, callback.Channel.ID)
+}
+
+// webhookMessages returns the messages sent to a response URL from their
+// blocks.
+func webhookMessages(messages [][]slack.Block, responseType string) []*slack.WebhookMessage {
+	msgs := make([]*slack.WebhookMessage, 0, len(messages))
+	for _, blocks := range messages {
+		msgs = append(msgs, &slack.WebhookMessage{
+			ResponseType: responseType,
+			Blocks:       &slack.Blocks{BlockSet: blocks},
+		})
+	}
+	return msgs
+}
+
+// respond sends the messages to the response URL of a slash command or an
+// interaction, one after the other so they are shown in order. The messages
+// left are dropped when one fails.
+func (s *Server) respond(ctx context.Context, responseURL string, msgs ...*slack.WebhookMessage) {
+	for i, msg := range msgs {
+		if err := slack.PostWebhookCustomHTTPContext(ctx, responseURL, s.slackClient, msg); err != nil {
+			slog.ErrorContext(ctx, "Error sending message to the response URL", "message", i, "messages", len(msgs), "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
+)
+
+// givenResponseURL returns a response URL receiving the messages sent in
+// response to the interactions.
+func givenResponseURL(t *testing.T) (string, <-chan slack.WebhookMessage) {
+	t.Helper()
+
+	messages := make(chan slack.WebhookMessage, 1)
+	responses := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("expected a JSON message: %v", err)
+		}
+		messages <- msg
+	}))
+	t.Cleanup(responses.Close)
+	return responses.URL, messages
+}
+
+func sendInteraction(t *testing.T, handler http.Handler, responseURL, actionID, value string) {
+	t.Helper()
+
+	payload := fmt.Sprintf(`{
+		"type": "block_actions",
+		"team": {"id": %q},
+		"channel": {"id": %q},
+		"user": {"id": "U-OTHER"},
+		"response_url": %q,
+		"actions": [{"block_id": %q, "action_id": %q, "value": %q}]
+	}`, mockTeamID, mockChannel, responseURL, slackmsg.BlockIDActions, actionID, value)
+	body := url.Values{"payload": {payload}}.Encode()
+	req := utils.CreateValidSlackRequest(mockSigningSecret, body)
+	req.URL.Path = "/slack/interactions"
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the interaction to be acknowledged, got %v", rr.Code)
+	}
+}
+
+func receive(t *testing.T, messages <-chan slack.WebhookMessage) slack.WebhookMessage {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("expected a message on the response URL")
+		return slack.WebhookMessage{}
+	}
+}
+
+// blocksJSON returns the blocks of the message as JSON, with the mentions
+// unescaped.
+func blocksJSON(t *testing.T, msg slack.WebhookMessage) string {
+	t.Helper()
+
+	raw, err := json.Marshal(msg.Blocks)
+	if err != nil {
+		t.Fatalf("failed to encode the blocks: %v", err)
+	}
+	return strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(raw))
+}
+
+func givenInteractiveServer(opts ...server.ServerOption) http.Handler {
+	opts = append([]server.ServerOption{
+		server.WithSettingsStore(store.NewMemorySettingsStore()),
+		server.WithFileSettings(server.FileSettings{
+			Channels: []store.ChannelSettings{{TeamID: mockTeamID, ChannelID: mockChannel, Schedules: []string{"Platform"}}},
+		}),
+	}, opts...)
+	return server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		opts...,
+	).Handler()
+}
+
+func TestSlashCommand_Buttons(t *testing.T) {
+	handler := givenInteractiveServer()
+
+	for text, value := range map[string]string{"": "channel", "all": "all"} {
+		var msg slack.Msg
+		json.Unmarshal([]byte(sendSlashCommand(handler, "U-OTHER", mockChannel, text)), &msg)
+
+		actions, ok := msg.Blocks.BlockSet[len(msg.Blocks.BlockSet)-1].(*slack.ActionBlock)
+		if !ok || actions.BlockID != slackmsg.BlockIDActions || len(actions.Elements.ElementSet) != 2 {
+			t.Fatalf("expected the buttons last, got %+v", msg.Blocks.BlockSet)
+		}
+		for _, element := range actions.Elements.ElementSet {
+			if button := element.(*slack.ButtonBlockElement); button.Value != value {
+				t.Errorf("expected the buttons of %q to hold %q, got %q", text, value, button.Value)
+			}
+		}
+	}
+}
+
+func TestInteraction_Refresh(t *testing.T) {
+	auditLog := audit.NewLog()
+	handler := givenInteractiveServer(server.WithAuditLog(auditLog))
+	responseURL, messages := givenResponseURL(t)
+
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDRefresh, "channel")
+
+	msg := receive(t, messages)
+	if !msg.ReplaceOriginal || msg.ResponseType != slack.ResponseTypeEphemeral {
+		t.Errorf("expected the original message to be replaced, got %+v", msg)
+	}
+	raw := blocksJSON(t, msg)
+	if !strings.Contains(raw, "*Platform*") || strings.Contains(raw, "*Payments*") {
+		t.Errorf("expected the schedules of the channel, got %s", raw)
+	}
+	if !strings.Contains(raw, slackmsg.ActionIDRefresh) {
+		t.Errorf("expected the buttons to be kept, got %s", raw)
+	}
+
+	entries := auditLog.Recent(audit.Query{TeamID: mockTeamID})
+	if len(entries) != 1 || entries[0].Command != "button" || entries[0].Action != "refresh" || entries[0].Result != "ok" {
+		t.Errorf("expected the refresh to be audited, got %+v", entries)
+	}
+}
+
+func TestInteraction_RefreshDenied(t *testing.T) {
+	handler := givenInteractiveServer(server.WithFileSettings(server.FileSettings{
+		Policies: []policy.Rule{{Action: policy.ActionRefresh, Users: []string{mockAdminID}}},
+	}))
+	responseURL, messages := givenResponseURL(t)
+
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDRefresh, "channel")
+
+	msg := receive(t, messages)
+	if msg.ReplaceOriginal || msg.Text != "Only <@U-ADMIN> can refresh the schedules." {
+		t.Errorf("expected the denial, got %+v", msg)
+	}
+}
+
+func TestInteraction_Share(t *testing.T) {
+	handler := givenInteractiveServer()
+	responseURL, messages := givenResponseURL(t)
+
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDShare, "all")
+
+	msg := receive(t, messages)
+	if msg.ResponseType != slack.ResponseTypeInChannel || msg.ReplaceOriginal {
+		t.Errorf("expected a message in the channel, got %+v", msg)
+	}
+	raw := blocksJSON(t, msg)
+	if !strings.Contains(raw, "*Payments*") || !strings.Contains(raw, "Shared by <@U-OTHER>") {
+		t.Errorf("expected every schedule shared by the user, got %s", raw)
+	}
+	if strings.Contains(raw, slackmsg.ActionIDShare) {
+		t.Errorf("expected no button in the channel, got %s", raw)
+	}
+}
+
+// givenCrowdedCompassClient returns schedules with too many responders to fit
+// in a single message.
+func givenCrowdedCompassClient(schedules, responders int) *api.CompassClient {
+	values := make([]string, schedules)
+	for i := range values {
+		values[i] = fmt.Sprintf(`{"id": "schedule-%d", "name": "Schedule %d"}`, i+1, i+1)
+	}
+	participants := make([]string, responders)
+	for i := range participants {
+		participants[i] = fmt.Sprintf(`{"id": "user-%d", "type": "user"}`, i)
+	}
+
+	return api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithHttpClient(&http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := `{"onCallParticipants": [` + strings.Join(participants, ",") + `]}`
+			if strings.HasSuffix(req.URL.Path, "/schedules") {
+				body = `{"values": [` + strings.Join(values, ",") + `]}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}))
+}
+
+// applyResponses updates the messages shown as Slack does with the messages
+// sent to the response URL after a click on the message at index clicked.
+func applyResponses(t *testing.T, shown []string, clicked int, messages <-chan slack.WebhookMessage, n int) []string {
+	t.Helper()
+
+	for range n {
+		msg := receive(t, messages)
+		if msg.ReplaceOriginal {
+			shown[clicked] = blocksJSON(t, msg)
+			continue
+		}
+		shown = append(shown, blocksJSON(t, msg))
+	}
+	return shown
+}
+
+// scheduleOrder returns the numbers of the schedules shown, in order.
+func scheduleOrder(shown []string) []int {
+	var order []int
+	for _, raw := range shown {
+		for _, match := range regexp.MustCompile(`\*Schedule (\d+)\*`).FindAllStringSubmatch(raw, -1) {
+			n, _ := strconv.Atoi(match[1])
+			order = append(order, n)
+		}
+	}
+	return order
+}
+
+func TestInteraction_CrowdedPages(t *testing.T) {
+	handler := server.NewServer(
+		app.NewApp(givenCrowdedCompassClient(4, 300), givenJiraClient()),
+		"", 0, mockSigningSecret,
+	).Handler()
+	responseURL, messages := givenResponseURL(t)
+
+	// The first page is a single message holding the buttons
+	var msg slack.Msg
+	if err := json.Unmarshal([]byte(sendSlashCommand(handler, "U1", mockChannel, "")), &msg); err != nil {
+		t.Fatalf("expected the answer in the response, got %v", err)
+	}
+	if len(msg.Blocks.BlockSet) > slackmsg.MaxBlocks {
+		t.Fatalf("expected at most %d blocks, got %d", slackmsg.MaxBlocks, len(msg.Blocks.BlockSet))
+	}
+	raw, _ := json.Marshal(msg.Blocks)
+	shown := []string{strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(raw))}
+	first := scheduleOrder(shown)
+	if len(first) == 0 || len(first) == 4 {
+		t.Fatalf("expected the schedules over several pages, got %v", first)
+	}
+
+	// Turning the page and refreshing replace the message in place
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDNextPage, "channel:1")
+	shown = applyResponses(t, shown, 0, messages, 1)
+	second := scheduleOrder(shown)
+	if len(shown) != 1 || len(second) == 0 || second[0] != first[len(first)-1]+1 {
+		t.Errorf("expected the next page in place of the first one, got %v after %v", second, first)
+	}
+
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDRefresh, "channel:1")
+	shown = applyResponses(t, shown, 0, messages, 1)
+	if refreshed := scheduleOrder(shown); len(shown) != 1 || !slices.Equal(refreshed, second) {
+		t.Errorf("expected the page refreshed in place, got %v", refreshed)
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("expected no other message, got %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInteraction_ShareSplit(t *testing.T) {
+	handler := server.NewServer(
+		app.NewApp(givenCrowdedCompassClient(1, 3000), givenJiraClient()),
+		"", 0, mockSigningSecret,
+	).Handler()
+	responseURL, messages := givenResponseURL(t)
+
+	// The answer lists the first responders, the shared messages all of them
+	body := sendSlashCommand(handler, "U1", mockChannel, "")
+	if !regexp.MustCompile(`and \d+ more`).MatchString(body) {
+		t.Errorf("expected the responders left out to be counted, got: %s", body)
+	}
+
+	sendInteraction(t, handler, responseURL, slackmsg.ActionIDShare, "channel")
+	var shown []string
+	for {
+		msg := receive(t, messages)
+		if msg.ResponseType != slack.ResponseTypeInChannel || msg.ReplaceOriginal || len(msg.Blocks.BlockSet) > slackmsg.MaxBlocks {
+			t.Errorf("expected messages posted in the channel, got %+v", msg)
+		}
+		shown = append(shown, blocksJSON(t, msg))
+		if strings.Contains(shown[len(shown)-1], "Shared by") {
+			break
+		}
+	}
+	if len(shown) < 2 {
+		t.Fatalf("expected the schedule to be split, got %d message", len(shown))
+	}
+	if order := scheduleOrder(shown); !slices.Equal(order, []int{1}) {
+		t.Errorf("expected the schedule first, got %v", order)
+	}
+	if names := strings.Count(strings.Join(shown, ""), "Test User"); names != 3000 {
+		t.Errorf("expected every responder once, got %d", names)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// localeCacheTTL bounds how long a change of the language or the
+	// timezone of a user takes to show
+	localeCacheTTL = time.Hour
+	// localeFailureTTL bounds how long the default localizer is used for a
+	// user who couldn't be looked up, before trying again
+	localeFailureTTL = 5 * time.Minute
+	// localeTimeout bounds the lookup of a user, the answer to a slash command
+	// is due within 3 seconds
+	localeTimeout = time.Second
+	// maxLocaleEntries caps the users kept in the cache
+	maxLocaleEntries = 10000
+)
+
+// WithDefaultLocalizer sets the language and the timezone of the answers when
+// the ones of the user can't be looked up. They default to English and UTC.
+func WithDefaultLocalizer(l slackmsg.Localizer) ServerOption {
+	return func(s *Server) {
+		s.defaultLocalizer = l
+	}
+}
+
+// localeCache keeps the localizers of the users looked up recently. The
+// expired entries are swept once per TTL, and an entry is evicted when the
+// cache is full.
+type localeCache struct {
+	mu      sync.Mutex
+	entries map[string]localeEntry
+	sweptAt time.Time
+}
+
+type localeEntry struct {
+	localizer slackmsg.Localizer
+	expiresAt time.Time
+}
+
+func (c *localeCache) get(key string) (slackmsg.Localizer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return slackmsg.Localizer{}, false
+	}
+	return entry.localizer, true
+}
+
+func (c *localeCache) put(key string, l slackmsg.Localizer, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]localeEntry)
+	}
+	now := time.Now()
+	if now.Sub(c.sweptAt) >= localeCacheTTL {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweptAt = now
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxLocaleEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = localeEntry{localizer: l, expiresAt: now.Add(ttl)}
+}
+
+type localizerKey struct{}
+
+// withLocalizer attaches the localizer of the user running the request.
+func withLocalizer(ctx context.Context, l slackmsg.Localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+// localizerFromContext returns the localizer of the user running the request,
+// the zero Localizer when unknown.
+func localizerFromContext(ctx context.Context) slackmsg.Localizer {
+	l, _ := ctx.Value(localizerKey{}).(slackmsg.Localizer)
+	return l
+}
+
+// localizer returns the localizer of the user, from the locale and the
+// timezone of their Slack profile. Looking them up needs the bot token of the
+// installation and the users:read scope, the default localizer is returned
+// otherwise. Failed lookups are cached too, for a shorter time, so a user
+// who can't be looked up doesn't wait for the timeout on every request.
+func (s *Server) localizer(ctx context.Context, teamID, userID string) slackmsg.Localizer {
+	installation, ok := installationFromContext(ctx)
+	if !ok || installation.BotToken == "" || userID == "" {
+		return s.defaultLocalizer
+	}
+
+	key := teamID + "/" + userID
+	if l, ok := s.locales.get(key); ok {
+		return l
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, localeTimeout)
+	defer cancel()
+
+	client := slack.New(installation.BotToken, slack.OptionHTTPClient(s.slackClient))
+	user, err := client.GetUserInfoContext(lookupCtx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "Error fetching user locale", "teamID", teamID, "userID", userID, "error", err)
+		// A cancelled request says nothing about the user
+		if ctx.Err() == nil {
+			s.locales.put(key, s.defaultLocalizer, localeFailureTTL)
+		}
+		return s.defaultLocalizer
+	}
+
+	l := slackmsg.NewLocalizer(user.Locale, user.TZ)
+	s.locales.put(key, l, localeCacheTTL)
+	return l
+}
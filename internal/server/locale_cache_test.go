@@ -0,0 +1,57 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+)
+
+func TestLocaleCache_SweepsExpiredEntries(t *testing.T) {
+	c := &localeCache{}
+	c.put("T1/U1", slackmsg.Localizer{}, localeCacheTTL)
+	c.put("T1/U2", slackmsg.Localizer{}, localeCacheTTL)
+
+	// Both entries expired a while ago, and so did the last sweep
+	for key, entry := range c.entries {
+		entry.expiresAt = time.Now().Add(-time.Minute)
+		c.entries[key] = entry
+	}
+	c.sweptAt = time.Now().Add(-localeCacheTTL)
+
+	c.put("T1/U3", slackmsg.Localizer{}, localeCacheTTL)
+	if len(c.entries) != 1 {
+		t.Errorf("expected the expired entries to be swept, got %d entries", len(c.entries))
+	}
+	if _, ok := c.get("T1/U3"); !ok {
+		t.Error("expected the new entry to be cached")
+	}
+}
+
+func TestLocaleCache_Capped(t *testing.T) {
+	c := &localeCache{}
+	for i := range maxLocaleEntries + 10 {
+		c.put("T1/U"+strconv.Itoa(i), slackmsg.Localizer{}, localeCacheTTL)
+	}
+
+	if len(c.entries) != maxLocaleEntries {
+		t.Errorf("expected at most %d entries, got %d", maxLocaleEntries, len(c.entries))
+	}
+	if _, ok := c.get("T1/U" + strconv.Itoa(maxLocaleEntries+9)); !ok {
+		t.Error("expected the last entry to be cached")
+	}
+}
+
+func TestLocaleCache_ExpiresAfterTTL(t *testing.T) {
+	c := &localeCache{}
+	c.put("T1/U1", slackmsg.Localizer{}, localeFailureTTL)
+
+	if _, ok := c.get("T1/U1"); !ok {
+		t.Fatal("expected the entry to be cached")
+	}
+	entry := c.entries["T1/U1"]
+	if d := time.Until(entry.expiresAt); d > localeFailureTTL {
+		t.Errorf("expected the entry to expire within %v, got %v", localeFailureTTL, d)
+	}
+}
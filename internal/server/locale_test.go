@@ -0,0 +1,239 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// givenUsersInfo returns a Slack client answering users.info with the body,
+// and counting the calls.
+func givenUsersInfo(t *testing.T, body string, calls *atomic.Int32) *http.Client {
+	t.Helper()
+
+	return &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "users.info") {
+				t.Errorf("unexpected Slack API call to %s", req.URL.Path)
+			}
+			calls.Add(1)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		}),
+	}
+}
+
+// givenCompassStatus answers every Compass call with the given status.
+func givenCompassStatus(status int) *api.CompassClient {
+	return api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithHttpClient(&http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(strings.NewReader(`{"message": "` + http.StatusText(status) + `"}`)),
+			}, nil
+		}),
+	}))
+}
+
+func givenInstalledServer(slackClient *http.Client, opts ...server.ServerOption) http.Handler {
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: mockTeamID, BotToken: "xoxb-1"})
+	return givenInteractiveServer(append([]server.ServerOption{
+		server.WithTokenStore(tokens),
+		server.WithSlackHttpClient(slackClient),
+	}, opts...)...)
+}
+
+// buttonLabels returns the labels of the buttons ending the answer.
+func buttonLabels(t *testing.T, body string) []string {
+	t.Helper()
+
+	var msg slack.Msg
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		t.Fatalf("expected a JSON message, got: %s", body)
+	}
+	actions, ok := msg.Blocks.BlockSet[len(msg.Blocks.BlockSet)-1].(*slack.ActionBlock)
+	if !ok {
+		t.Fatalf("expected the buttons last, got: %s", body)
+	}
+	var labels []string
+	for _, element := range actions.Elements.ElementSet {
+		labels = append(labels, element.(*slack.ButtonBlockElement).Text.Text)
+	}
+	return labels
+}
+
+func TestSlashCommand_Localized(t *testing.T) {
+	var calls atomic.Int32
+	handler := givenInstalledServer(givenUsersInfo(t, `{"ok": true, "user": {"id": "U-OTHER", "locale": "fr-CA", "tz": "America/Toronto"}}`, &calls))
+
+	for range 2 {
+		body := sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+		if labels := buttonLabels(t, body); strings.Join(labels, ",") != "Actualiser,Partager dans le canal" {
+			t.Errorf("expected the buttons in French, got %v", labels)
+		}
+		if !strings.Contains(body, "Mis à jour le ") || !(strings.Contains(body, " EDT") || strings.Contains(body, " EST")) {
+			t.Errorf("expected the update time in French and in the timezone of the user, got: %s", body)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the user to be looked up once, got %d calls", calls.Load())
+	}
+}
+
+func TestSlashCommand_LocaleFallback(t *testing.T) {
+	var calls atomic.Int32
+	handler := givenInstalledServer(
+		givenUsersInfo(t, `{"ok": false, "error": "missing_scope"}`, &calls),
+		server.WithDefaultLocalizer(slackmsg.NewLocalizer("fr", "Europe/Paris")),
+	)
+
+	for range 2 {
+		body := sendSlashCommand(handler, "U-OTHER", mockChannel, "")
+		if labels := buttonLabels(t, body); strings.Join(labels, ",") != "Actualiser,Partager dans le canal" {
+			t.Errorf("expected the default language, got %v", labels)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the failed lookup to be cached, got %d calls", calls.Load())
+	}
+}
+
+// reply is a slash command and the text of its answer.
+type reply struct {
+	Command string `json:"command"`
+	Text    string `json:"text"`
+}
+
+func TestReplies_Golden(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		userInfo string
+	}{
+		{"en", `{"ok": true, "user": {"locale": "en-US", "tz": "America/New_York"}}`},
+		{"fr", `{"ok": true, "user": {"locale": "fr-CA", "tz": "America/Toronto"}}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			usersInfo := givenUsersInfo(t, tc.userInfo, &calls)
+
+			var replies []reply
+			send := func(handler http.Handler, userID, text string) {
+				var msg slack.Msg
+				body := sendSlashCommand(handler, userID, mockChannel, text)
+				if err := json.Unmarshal([]byte(body), &msg); err != nil {
+					t.Fatalf("expected a JSON message for %q, got: %s", text, body)
+				}
+				replies = append(replies, reply{Command: strings.TrimSpace(fmt.Sprintf("%s: /oncall %s", userID, text)), Text: msg.Text})
+			}
+
+			handler := givenInstalledServer(usersInfo,
+				server.WithAdmins([]string{mockAdminID}),
+				server.WithAuditLog(audit.NewLog()),
+				server.WithFileSettings(server.FileSettings{
+					Channels: []store.ChannelSettings{{TeamID: mockTeamID, ChannelID: mockChannel, Schedules: []string{"Platform"}}},
+					Policies: []policy.Rule{
+						{Action: policy.ActionRefresh, Users: []string{"U-SRE"}, Channels: []string{mockChannel}},
+						{Action: policy.ActionRefresh, Admins: true},
+						{Action: policy.ActionAudit, Channels: []string{"C-OPS"}},
+					},
+				}),
+			)
+			for _, command := range []struct{ userID, text string }{
+				{"U-OTHER", "help"},
+				{"U-OTHER", "confg"},
+				{"U-OTHER", "config"},
+				{"U-OTHER", "config show"},
+				{"U-OTHER", "config set schedules=Platform"},
+				{"U-OTHER", "refresh"},
+				{"U-OTHER", "audit"},
+				{mockAdminID, "config set schedules=Nope"},
+				{mockAdminID, "config set workspace schedules=Platform"},
+				{mockAdminID, "config set visibility=public"},
+				{mockAdminID, "config show"},
+				{mockAdminID, "config unset schedules"},
+				{mockAdminID, "audit 5 <@U-NOBODY>"},
+				{mockAdminID, "audit bogus"},
+			} {
+				send(handler, command.userID, command.text)
+			}
+
+			// Without settings store and audit log
+			tokens := store.NewMemoryTokenStore()
+			tokens.Save(store.Installation{TeamID: mockTeamID, BotToken: "xoxb-1"})
+			handler = server.NewServer(
+				app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+				"", 0, mockSigningSecret,
+				server.WithTokenStore(tokens),
+				server.WithSlackHttpClient(usersInfo),
+			).Handler()
+			send(handler, "U-OTHER", "config")
+			send(handler, "U-OTHER", "audit")
+
+			// Without installation, the user can't be looked up
+			handler = server.NewServer(
+				app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+				"", 0, mockSigningSecret,
+				server.WithTokenStore(store.NewMemoryTokenStore()),
+				server.WithDefaultLocalizer(slackmsg.NewLocalizer(tc.name, "")),
+			).Handler()
+			send(handler, "U-OTHER", "")
+
+			// Compass rejects the credentials or throttles the requests
+			for _, status := range []int{http.StatusUnauthorized, http.StatusTooManyRequests} {
+				handler = server.NewServer(
+					app.NewApp(givenCompassStatus(status), givenJiraClient()),
+					"", 0, mockSigningSecret,
+					server.WithTokenStore(tokens),
+					server.WithSlackHttpClient(usersInfo),
+				).Handler()
+				send(handler, "U-OTHER", "")
+			}
+
+			// The mentions are kept readable
+			var got bytes.Buffer
+			encoder := json.NewEncoder(&got)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(replies); err != nil {
+				t.Fatalf("failed to encode the replies: %v", err)
+			}
+			golden := filepath.Join("testdata", tc.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s, run the tests with -update: %v", golden, err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("replies differ from %s, run the tests with -update to review the changes:\n%s", golden, got.String())
+			}
+		})
+	}
+}
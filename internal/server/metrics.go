@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/metrics"
+)
+
+const (
+	outcomeOK           = "ok"
+	outcomeError        = "error"
+	outcomeDenied       = "denied"
+	outcomeNotInstalled = "not_installed"
+)
+
+type outcomeKey struct{}
+
+// commandOutcome is set by the handlers when the slash command did not succeed
+// but was answered with a 200, as Slack expects.
+type commandOutcome struct {
+	value string
+}
+
+// setOutcome records the outcome of the slash command being handled.
+func setOutcome(ctx context.Context, outcome string) {
+	if o, ok := ctx.Value(outcomeKey{}).(*commandOutcome); ok {
+		o.value = outcome
+	}
+}
+
+// observeLatency records the time taken by the handler.
+func observeLatency(handler string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		metrics.ObserveSlackRequest(handler, time.Since(start))
+	})
+}
+
+// observeCommand counts the slash commands by command and outcome, the
+// commands labelled by the router.
+func observeCommand(rt *commandRouter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read before the handlers consume the body
+		command := rt.label(r.FormValue("command"), r.FormValue("text"))
+		outcome := &commandOutcome{value: outcomeOK}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), outcomeKey{}, outcome)))
+
+		if sw.status >= http.StatusBadRequest && outcome.value == outcomeOK {
+			outcome.value = outcomeError
+		}
+		metrics.ObserveCommand(command, outcome.value)
+	})
+}
+
+// observeAPI counts the requests to the REST API by endpoint and status code.
+func observeAPI(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		metrics.ObserveAPIRequest(endpoint, sw.status)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
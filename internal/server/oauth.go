@@ -0,0 +1,149 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/slack-go/slack"
+)
+
+const (
+	slackAuthorizeUrl = "https://slack.com/oauth/v2/authorize"
+	oauthStateCookie  = "pompiers_oauth_state"
+	oauthStateTTL     = 10 * time.Minute
+)
+
+var (
+	// defaultOAuthScopes covers the slash command, the admin and usergroup
+	// lookups of the authorization policies and the locale of the users
+	defaultOAuthScopes = []string{"commands", "users:read", "usergroups:read"}
+)
+
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// handleInstall redirects the browser to the Slack consent screen. The state
+// is kept in a short-lived cookie to protect the redirect against CSRF.
+func (s *Server) handleInstall(w http.ResponseWriter, r *http.Request) {
+	state, err := newOAuthState()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating OAuth state", "error", err)
+		http.Error(w, "Error starting installation", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/slack/oauth_redirect",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	scopes := s.oauth.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOAuthScopes
+	}
+
+	query := url.Values{
+		"client_id": {s.oauth.ClientID},
+		"scope":     {strings.Join(scopes, ",")},
+		"state":     {state},
+	}
+	if s.oauth.RedirectURL != "" {
+		query.Set("redirect_uri", s.oauth.RedirectURL)
+	}
+
+	http.Redirect(w, r, slackAuthorizeUrl+"?"+query.Encode(), http.StatusFound)
+}
+
+// handleOAuthRedirect exchanges the temporary code for a bot token and stores
+// the resulting installation.
+func (s *Server) handleOAuthRedirect(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		slog.WarnContext(r.Context(), "Slack installation was cancelled", "error", errCode)
+		http.Error(w, "Installation cancelled", http.StatusForbidden)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	state := query.Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		slog.WarnContext(r.Context(), "Invalid OAuth state", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "Invalid OAuth state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/slack/oauth_redirect", MaxAge: -1})
+
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "Missing OAuth code", http.StatusBadRequest)
+		return
+	}
+
+	res, err := slack.GetOAuthV2ResponseContext(
+		r.Context(),
+		s.slackClient,
+		s.oauth.ClientID,
+		s.oauth.ClientSecret,
+		code,
+		s.oauth.RedirectURL,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exchanging OAuth code", "error", err)
+		http.Error(w, "Error completing installation", http.StatusBadGateway)
+		return
+	}
+
+	installation := store.Installation{
+		TeamID:       res.Team.ID,
+		TeamName:     res.Team.Name,
+		EnterpriseID: res.Enterprise.ID,
+		AppID:        res.AppID,
+		BotUserID:    res.BotUserID,
+		BotToken:     res.AccessToken,
+		Scope:        res.Scope,
+		InstalledBy:  res.AuthedUser.ID,
+		InstalledAt:  time.Now().UTC(),
+	}
+	if err := s.tokens.Save(installation); err != nil {
+		slog.ErrorContext(r.Context(), "Error saving installation", "teamID", installation.TeamID, "error", err)
+		http.Error(w, "Error completing installation", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(
+		r.Context(),
+		"Slack workspace installed",
+		"teamID", installation.TeamID,
+		"teamName", installation.TeamName,
+		"installedBy", installation.InstalledBy,
+	)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Pompiers was installed in %s. You can close this window.", installation.TeamName)
+}
+
+func newOAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,166 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/server"
+)
+
+const (
+	mockClientID     = "mock-client-id"
+	mockClientSecret = "mock-client-secret"
+)
+
+func givenOAuthServer(t *testing.T, tokens store.TokenStore) *server.Server {
+	t.Helper()
+
+	slackClient := &http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "oauth.v2.access") {
+				t.Errorf("unexpected Slack API call to %s", req.URL.Path)
+			}
+			req.ParseForm()
+			if req.PostForm.Get("code") != "mock-code" {
+				t.Errorf("expected code 'mock-code', got '%s'", req.PostForm.Get("code"))
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body: io.NopCloser(strings.NewReader(`{
+					"ok": true,
+					"access_token": "xoxb-mock",
+					"scope": "commands,users:read,usergroups:read",
+					"bot_user_id": "U-BOT",
+					"app_id": "A1",
+					"team": {"id": "T1", "name": "Test Team"},
+					"authed_user": {"id": "U1"}
+				}`)),
+			}, nil
+		}),
+	}
+
+	return server.NewServer(
+		app.NewApp(givenCompassClient(false), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithTokenStore(tokens),
+		server.WithOAuth(server.OAuthConfig{ClientID: mockClientID, ClientSecret: mockClientSecret}),
+		server.WithSlackHttpClient(slackClient),
+	)
+}
+
+func TestOAuthInstallFlow(t *testing.T) {
+	tokens := store.NewMemoryTokenStore()
+	handler := givenOAuthServer(t, tokens).Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slack/install", nil))
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected status Found, got %v", rr.Code)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect location: %v", err)
+	}
+	if location.Query().Get("client_id") != mockClientID {
+		t.Errorf("expected client_id '%s', got '%s'", mockClientID, location.Query().Get("client_id"))
+	}
+	if location.Query().Get("scope") != "commands,users:read,usergroups:read" {
+		t.Errorf("expected scope 'commands,users:read,usergroups:read', got '%s'", location.Query().Get("scope"))
+	}
+	state := location.Query().Get("state")
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != state {
+		t.Fatalf("expected state cookie matching '%s', got %v", state, cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/slack/oauth_redirect?code=mock-code&state="+state, nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v with body: %s", rr.Code, rr.Body.String())
+	}
+	installation, err := tokens.Get("T1")
+	if err != nil {
+		t.Fatalf("expected installation to be saved: %v", err)
+	}
+	if installation.BotToken != "xoxb-mock" {
+		t.Errorf("expected bot token 'xoxb-mock', got '%s'", installation.BotToken)
+	}
+	if installation.InstalledBy != "U1" {
+		t.Errorf("expected installer 'U1', got '%s'", installation.InstalledBy)
+	}
+}
+
+func TestOAuthRedirect_InvalidState(t *testing.T) {
+	tokens := store.NewMemoryTokenStore()
+	handler := givenOAuthServer(t, tokens).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/slack/oauth_redirect?code=mock-code&state=forged", nil)
+	req.AddCookie(&http.Cookie{Name: "pompiers_oauth_state", Value: "expected"})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status BadRequest, got %v", rr.Code)
+	}
+	if _, err := tokens.Get("T1"); err == nil {
+		t.Error("expected no installation to be saved")
+	}
+}
+
+func TestSlashCommand_UnknownWorkspace(t *testing.T) {
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: "T1", BotToken: "xoxb-1"})
+	handler := givenOAuthServer(t, tokens).Handler()
+
+	body := "team_id=T2&command=%2Foncall"
+	req := utils.CreateValidSlackRequest(mockSigningSecret, body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "not installed") {
+		t.Errorf("expected not installed message, got: %s", rr.Body.String())
+	}
+
+	body = "team_id=T1&command=%2Foncall"
+	req = utils.CreateValidSlackRequest(mockSigningSecret, body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "Test User") {
+		t.Errorf("expected schedule for installed workspace, got: %s", rr.Body.String())
+	}
+}
+
+func TestEvents_AppUninstalled(t *testing.T) {
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: "T1", BotToken: "xoxb-1"})
+	handler := givenOAuthServer(t, tokens).Handler()
+
+	body := `{"type":"event_callback","team_id":"T1","event":{"type":"app_uninstalled"}}`
+	req := utils.CreateValidSlackRequest(mockSigningSecret, body)
+	req.URL.Path = "/slack/events"
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status OK, got %v", rr.Code)
+	}
+	if _, err := tokens.Get("T1"); err == nil {
+		t.Error("expected installation to be removed")
+	}
+}
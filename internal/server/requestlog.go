@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/logging"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+type requestLogKey struct{}
+
+// requestLog holds the attributes learnt while handling the request, logged
+// along with its outcome.
+type requestLog struct {
+	attrs []slog.Attr
+}
+
+// withRequestLogging scopes the records logged while handling the request,
+// with slog.InfoContext and the like, with a request ID, and logs the outcome
+// of the request once handled. It wraps the authentication middlewares so
+// that the rejected requests are logged too.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := newRequestID()
+		w.Header().Set(requestIDHeader, requestID)
+
+		log := &requestLog{}
+		ctx := context.WithValue(logging.WithAttrs(r.Context(), slog.String("request_id", requestID)), requestLogKey{}, log)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if sw.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		slog.Log(
+			logging.WithAttrs(ctx, log.attrs...),
+			level,
+			"Request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// withSlackAttrs adds the workspace, user and command the request comes from
+// to the records, and to the outcome logged by withRequestLogging. It must
+// run once the request is authenticated, the form is parsed.
+func withSlackAttrs(rt *commandRouter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attrs := slackAttrs(rt, r)
+		if log, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+			log.attrs = append(log.attrs, attrs...)
+		}
+		next.ServeHTTP(w, r.WithContext(logging.WithAttrs(r.Context(), attrs...)))
+	})
+}
+
+// slackAttrs returns the workspace, user and command of a slash command or
+// interactive payload. The form is parsed upfront, the handlers don't parse it
+// again.
+func slackAttrs(rt *commandRouter, r *http.Request) []slog.Attr {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return nil
+	}
+
+	if payload := r.FormValue("payload"); payload != "" {
+		var interaction struct {
+			Type string `json:"type"`
+			Team struct {
+				ID string `json:"id"`
+			} `json:"team"`
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		}
+		if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
+			return nil
+		}
+		return []slog.Attr{
+			slog.String("team_id", interaction.Team.ID),
+			slog.String("user_id", interaction.User.ID),
+			slog.String("interaction", interaction.Type),
+		}
+	}
+
+	command := r.FormValue("command")
+	if command == "" {
+		return nil
+	}
+	// The arguments may hold anything, only the known subcommands are logged
+	return []slog.Attr{
+		slog.String("team_id", r.FormValue("team_id")),
+		slog.String("user_id", r.FormValue("user_id")),
+		slog.String("command", rt.label(command, r.FormValue("text"))),
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
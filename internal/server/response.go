@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/slack-go/slack"
+)
+
+// response builds the reply to a slash command, written in the body of the
+// HTTP response. Replies are ephemeral, only shown to the user, unless made
+// public.
+type response struct {
+	msg slack.Msg
+}
+
+func newResponse() *response {
+	return &response{msg: slack.Msg{ResponseType: slack.ResponseTypeEphemeral}}
+}
+
+// text sets the text of the reply, shown in the notifications when there are
+// blocks.
+func (r *response) text(text string) *response {
+	r.msg.Text = text
+	return r
+}
+
+func (r *response) blocks(blocks ...slack.Block) *response {
+	r.msg.Blocks = slack.Blocks{BlockSet: blocks}
+	return r
+}
+
+// public posts the reply in the channel when set.
+func (r *response) public(public bool) *response {
+	r.msg.ResponseType = responseType(public)
+	return r
+}
+
+func (r *response) write(w http.ResponseWriter) {
+	payload, err := json.Marshal(r.msg)
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+func writeEphemeral(w http.ResponseWriter, text string) {
+	newResponse().text(text).write(w)
+}
+
+// responseType returns the response type of the messages posted in the
+// channel when public, only shown to the user otherwise.
+func responseType(public bool) string {
+	if public {
+		return slack.ResponseTypeInChannel
+	}
+	return slack.ResponseTypeEphemeral
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/domain"
+	"github.com/metriodev/pompiers/internal/logging"
+	"github.com/metriodev/pompiers/internal/tracing"
+)
+
+const (
+	apiPrefix = "/api/v1"
+	// apiVersion is the version of the JSON documents, changed along with the
+	// prefix when they break
+	apiVersion   = "v1"
+	apiKeyHeader = "X-API-Key"
+
+	apiErrMsg = "Error fetching the schedules from Compass, please try again later"
+)
+
+// openAPIDocument describes the REST API.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// APIKey authenticates a client of the REST API.
+type APIKey struct {
+	// Name identifies the client in the logs
+	Name string
+	Key  string
+}
+
+// ParseAPIKey reads a key optionally prefixed with the name of the client and
+// ":", e.g. "status-page:3f8a...". Keys without name are named after their
+// fingerprint.
+func ParseAPIKey(value string) (APIKey, error) {
+	name, key, found := strings.Cut(value, ":")
+	if !found {
+		name, key = "", name
+	}
+	if key == "" {
+		return APIKey{}, fmt.Errorf("empty API key")
+	}
+	if name == "" {
+		sum := sha256.Sum256([]byte(key))
+		name = hex.EncodeToString(sum[:4])
+	}
+	return APIKey{Name: name, Key: key}, nil
+}
+
+// WithAPIKeys enables the REST API, for the clients holding one of the keys.
+func WithAPIKeys(keys ...APIKey) ServerOption {
+	return func(s *Server) {
+		s.apiKeys = keys
+	}
+}
+
+// apiOnCall is the v1 document listing who is on call.
+type apiOnCall struct {
+	Version string `json:"version"`
+	// UpdatedAt is when the schedules were fetched from Compass
+	UpdatedAt time.Time     `json:"updatedAt"`
+	Schedules []apiSchedule `json:"schedules"`
+}
+
+// apiScheduleOnCall is the v1 document telling who is on call in a schedule.
+type apiScheduleOnCall struct {
+	Version   string      `json:"version"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+	Schedule  apiSchedule `json:"schedule"`
+}
+
+type apiSchedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+	// ShiftEnd is left out when unknown
+	ShiftEnd   *time.Time     `json:"shiftEnd,omitempty"`
+	Responders []apiResponder `json:"responders"`
+}
+
+type apiResponder struct {
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+}
+
+type apiError struct {
+	Version string `json:"version"`
+	Error   string `json:"error"`
+}
+
+func newAPISchedule(schedule domain.Schedule) apiSchedule {
+	s := apiSchedule{
+		ID:         schedule.ID,
+		Name:       schedule.Name,
+		URL:        schedule.URL,
+		Responders: make([]apiResponder, 0, len(schedule.Responders)),
+	}
+	if !schedule.ShiftEnd.IsZero() {
+		shiftEnd := schedule.ShiftEnd.UTC()
+		s.ShiftEnd = &shiftEnd
+	}
+	for _, responder := range schedule.Responders {
+		s.Responders = append(s.Responders, apiResponder{Name: responder.Name, AvatarURL: responder.AvatarURL})
+	}
+	return s
+}
+
+// APIHandler serves the REST API, nil when no API key is configured. The
+// requests don't come from Slack, they are authenticated with the API keys
+// instead of the Slack signatures, and the OpenAPI document is public.
+func (s *Server) APIHandler() http.Handler {
+	if len(s.apiKeys) == 0 {
+		return nil
+	}
+
+	// The routes match every method, for the catch-all not to answer 404 to
+	// the other methods on a known path
+	mux := http.NewServeMux()
+	mux.Handle(apiPrefix+"/openapi.json", observeAPI("openapi", allowGet(http.HandlerFunc(handleOpenAPI))))
+	mux.Handle(apiPrefix+"/oncall", observeAPI("oncall", allowGet(s.requireAPIKey(http.HandlerFunc(s.handleAPIOnCall)))))
+	mux.Handle(apiPrefix+"/schedules/{id}/oncall", observeAPI("schedule_oncall", allowGet(s.requireAPIKey(http.HandlerFunc(s.handleAPIScheduleOnCall)))))
+	mux.Handle(apiPrefix+"/", observeAPI("other", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "Not found")
+	})))
+	return withRequestLogging(tracing.Handler("api", mux))
+}
+
+// allowGet rejects the requests other than GET and HEAD with 405 Method Not
+// Allowed, the API being read-only.
+func allowGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIKey rejects the requests without one of the API keys, given as a
+// bearer token or in the X-API-Key header.
+func (s *Server) requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := s.authenticateAPI(r)
+		if !ok {
+			slog.WarnContext(r.Context(), "Unauthenticated API request", "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pompiers"`)
+			writeAPIError(w, http.StatusUnauthorized, "A valid API key is required, as a bearer token or in the "+apiKeyHeader+" header")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(logging.WithAttrs(r.Context(), slog.String("api_client", key.Name))))
+	})
+}
+
+func (s *Server) authenticateAPI(r *http.Request) (APIKey, bool) {
+	token := r.Header.Get(apiKeyHeader)
+	if scheme, credentials, found := strings.Cut(r.Header.Get("Authorization"), " "); found && strings.EqualFold(scheme, "Bearer") {
+		token = strings.TrimSpace(credentials)
+	}
+	if token == "" {
+		return APIKey{}, false
+	}
+
+	for _, key := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key.Key)) == 1 {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// handleAPIOnCall serves `GET /api/v1/oncall`, every schedule or the ones
+// named by the schedule query parameters, aliases included.
+func (s *Server) handleAPIOnCall(w http.ResponseWriter, r *http.Request) {
+	filter := app.ScheduleFilter{}
+	if names := r.URL.Query()["schedule"]; len(names) > 0 {
+		filter.Names = s.expandAliases(names)
+	}
+
+	schedule, err := s.currentSchedule(r.Context(), filter, false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching current on-call schedule", "error", err)
+		writeAPIError(w, http.StatusBadGateway, apiErrMsg)
+		return
+	}
+
+	response := apiOnCall{
+		Version:   apiVersion,
+		UpdatedAt: schedule.UpdatedAt.UTC(),
+		Schedules: make([]apiSchedule, 0, len(schedule.Schedules)),
+	}
+	for _, item := range schedule.Schedules {
+		response.Schedules = append(response.Schedules, newAPISchedule(item))
+	}
+	writeAPIJSON(w, http.StatusOK, response)
+}
+
+// handleAPIScheduleOnCall serves `GET /api/v1/schedules/{id}/oncall`, the
+// schedule with the given Compass ID.
+func (s *Server) handleAPIScheduleOnCall(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	schedule, err := s.currentSchedule(r.Context(), app.ScheduleFilter{IDs: []string{id}}, false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching current on-call schedule", "scheduleID", id, "error", err)
+		writeAPIError(w, http.StatusBadGateway, apiErrMsg)
+		return
+	}
+	if len(schedule.Schedules) == 0 {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Unknown schedule %s", id))
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, apiScheduleOnCall{
+		Version:   apiVersion,
+		UpdatedAt: schedule.UpdatedAt.UTC(),
+		Schedule:  newAPISchedule(schedule.Schedules[0]),
+	})
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPIDocument)
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, apiError{Version: apiVersion, Error: message})
+}
@@ -0,0 +1,190 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/server"
+)
+
+const mockAPIToken = "api-token"
+
+func givenAPIServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	key, err := server.ParseAPIKey("status-page:" + mockAPIToken)
+	if err != nil {
+		t.Fatalf("failed to parse the API key: %v", err)
+	}
+	return server.NewServer(
+		app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithAPIKeys(key),
+		server.WithFileSettings(server.FileSettings{Aliases: map[string][]string{"money": {"Payments"}}}),
+	).Handler()
+}
+
+func getAPI(handler http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+type apiDocument struct {
+	Version   string        `json:"version"`
+	Error     string        `json:"error"`
+	Schedules []apiSchedule `json:"schedules"`
+	Schedule  *apiSchedule  `json:"schedule"`
+}
+
+type apiSchedule struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Responders []struct {
+		Name string `json:"name"`
+	} `json:"responders"`
+}
+
+func decodeAPI(t *testing.T, rr *httptest.ResponseRecorder, status int) apiDocument {
+	t.Helper()
+
+	if rr.Code != status {
+		t.Fatalf("expected status %d, got %d with body: %s", status, rr.Code, rr.Body)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected JSON, got %q", contentType)
+	}
+	var doc apiDocument
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("expected a JSON document, got: %s", rr.Body)
+	}
+	if doc.Version != "v1" {
+		t.Errorf("expected a v1 document, got %q", doc.Version)
+	}
+	return doc
+}
+
+func TestAPI_Authentication(t *testing.T) {
+	handler := givenAPIServer(t)
+
+	for _, tc := range []struct {
+		headers map[string]string
+		status  int
+	}{
+		{nil, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Basic " + mockAPIToken}, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Bearer " + mockAPIToken}, http.StatusOK},
+		{map[string]string{"X-API-Key": mockAPIToken}, http.StatusOK},
+	} {
+		rr := getAPI(handler, "/api/v1/oncall", tc.headers)
+		if rr.Code != tc.status {
+			t.Errorf("expected %d with %v, got %d", tc.status, tc.headers, rr.Code)
+		}
+		if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("expected a WWW-Authenticate header with %v", tc.headers)
+		}
+	}
+}
+
+func TestAPI_OnCall(t *testing.T) {
+	handler := givenAPIServer(t)
+	auth := map[string]string{"Authorization": "Bearer " + mockAPIToken}
+
+	doc := decodeAPI(t, getAPI(handler, "/api/v1/oncall", auth), http.StatusOK)
+	if len(doc.Schedules) != 2 || doc.Schedules[0].Name != "Payments" || doc.Schedules[1].ID != "schedule-1" {
+		t.Fatalf("expected both schedules sorted by name, got %+v", doc.Schedules)
+	}
+	if len(doc.Schedules[0].Responders) != 1 || doc.Schedules[0].Responders[0].Name != "Test User" {
+		t.Errorf("expected the responders, got %+v", doc.Schedules[0].Responders)
+	}
+
+	doc = decodeAPI(t, getAPI(handler, "/api/v1/oncall?schedule=money", auth), http.StatusOK)
+	if len(doc.Schedules) != 1 || doc.Schedules[0].ID != "schedule-2" {
+		t.Errorf("expected the schedule of the alias, got %+v", doc.Schedules)
+	}
+}
+
+func TestAPI_ScheduleOnCall(t *testing.T) {
+	handler := givenAPIServer(t)
+	auth := map[string]string{"X-API-Key": mockAPIToken}
+
+	doc := decodeAPI(t, getAPI(handler, "/api/v1/schedules/schedule-1/oncall", auth), http.StatusOK)
+	if doc.Schedule == nil || doc.Schedule.Name != "Platform" {
+		t.Fatalf("expected the Platform schedule, got %+v", doc.Schedule)
+	}
+
+	doc = decodeAPI(t, getAPI(handler, "/api/v1/schedules/unknown/oncall", auth), http.StatusNotFound)
+	if !strings.Contains(doc.Error, "unknown") {
+		t.Errorf("expected the unknown schedule to be named, got %q", doc.Error)
+	}
+
+	decodeAPI(t, getAPI(handler, "/api/v1/schedules", auth), http.StatusNotFound)
+}
+
+func TestAPI_MethodNotAllowed(t *testing.T) {
+	handler := givenAPIServer(t)
+
+	for _, path := range []string{"/api/v1/oncall", "/api/v1/schedules/schedule-1/oncall", "/api/v1/openapi.json"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer "+mockAPIToken)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		decodeAPI(t, rr, http.StatusMethodNotAllowed)
+		if allow := rr.Header().Get("Allow"); allow != "GET, HEAD" {
+			t.Errorf("expected Allow header 'GET, HEAD' for %s, got %q", path, allow)
+		}
+	}
+}
+
+func TestAPI_OpenAPI(t *testing.T) {
+	rr := getAPI(givenAPIServer(t), "/api/v1/openapi.json", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the document without API key, got %d", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("expected a JSON document: %v", err)
+	}
+	for _, path := range []string{"/oncall", "/schedules/{id}/oncall"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("expected %s to be documented", path)
+		}
+	}
+}
+
+func TestAPI_Disabled(t *testing.T) {
+	handler := server.NewServer(app.NewApp(givenMultiScheduleCompassClient(), givenJiraClient()), "", 0, mockSigningSecret).Handler()
+
+	rr := getAPI(handler, "/api/v1/oncall", map[string]string{"Authorization": "Bearer " + mockAPIToken})
+	if rr.Code == http.StatusOK {
+		t.Errorf("expected the API to be disabled without API key, got %d", rr.Code)
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	key, err := server.ParseAPIKey("incident-bot:s3cr3t")
+	if err != nil || key.Name != "incident-bot" || key.Key != "s3cr3t" {
+		t.Errorf("expected a named key, got %+v, %v", key, err)
+	}
+	key, err = server.ParseAPIKey("s3cr3t")
+	if err != nil || key.Key != "s3cr3t" || key.Name == "" {
+		t.Errorf("expected a key named after its fingerprint, got %+v, %v", key, err)
+	}
+	if _, err := server.ParseAPIKey("incident-bot:"); err == nil {
+		t.Error("expected an empty key to be rejected")
+	}
+}
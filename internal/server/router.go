@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/slack-go/slack"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	oncallCommand = "/oncall"
+
+	// maxSuggestionDistance is how many edits away from a subcommand an
+	// unknown one may be to be suggested
+	maxSuggestionDistance = 2
+)
+
+// commandFunc handles a slash command. The text of the command still starts
+// with the subcommand.
+type commandFunc func(w http.ResponseWriter, r *http.Request, cmd slack.SlashCommand)
+
+// localizerFunc returns the localizer of a Slack user.
+type localizerFunc func(ctx context.Context, teamID, userID string) slackmsg.Localizer
+
+// route is a subcommand of a slash command.
+type route struct {
+	// name is the first word of the text, empty for the command alone
+	name string
+	// usage are the arguments shown in the help, after the name
+	usage       string
+	description slackmsg.MessageKey
+	handler     commandFunc
+}
+
+// commandRouter dispatches the slash commands to their handler by the
+// command, e.g. /oncall, and the first word of the text. `help` lists the
+// subcommands of a command, unless registered. The replies are rendered in
+// the language of the user, whose localizer is passed on to the handlers in
+// the request context.
+type commandRouter struct {
+	// commands holds the routes of every command, in the order they are
+	// listed in the help
+	commands  map[string][]route
+	localizer localizerFunc
+}
+
+// newCommandRouter returns a router looking up the users with localizer, or
+// answering in DefaultLanguage when nil.
+func newCommandRouter(localizer localizerFunc) *commandRouter {
+	if localizer == nil {
+		localizer = func(context.Context, string, string) slackmsg.Localizer { return slackmsg.Localizer{} }
+	}
+	return &commandRouter{commands: make(map[string][]route), localizer: localizer}
+}
+
+// Handle registers the handler of a subcommand. An empty name registers the
+// handler of the command without text.
+func (rt *commandRouter) Handle(command, name, usage string, description slackmsg.MessageKey, handler commandFunc) {
+	rt.commands[command] = append(rt.commands[command], route{
+		name:        name,
+		usage:       usage,
+		description: description,
+		handler:     handler,
+	})
+}
+
+func (rt *commandRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	cmd, err := slack.SlashCommandParse(r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error parsing slash command", "error", err)
+		http.Error(w, "Error parsing slash command", http.StatusBadRequest)
+		return
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String("slack.team_id", cmd.TeamID),
+		attribute.String("slack.channel_id", cmd.ChannelID),
+		attribute.String("slack.user_id", cmd.UserID),
+		attribute.String("slack.command", rt.label(cmd.Command, cmd.Text)),
+	)
+
+	l := rt.localizer(r.Context(), cmd.TeamID, cmd.UserID)
+	r = r.WithContext(withLocalizer(r.Context(), l))
+
+	routes, ok := rt.commands[cmd.Command]
+	if !ok {
+		slog.WarnContext(r.Context(), "Unknown slash command", "command", cmd.Command)
+		writeEphemeral(w, l.Text(slackmsg.MsgUnknownCommand, cmd.Command))
+		return
+	}
+
+	name, _ := cutWord(cmd.Text)
+	name = strings.ToLower(name)
+	for _, route := range routes {
+		if route.name == name {
+			route.handler(w, r, cmd)
+			return
+		}
+	}
+
+	if name == "help" {
+		writeEphemeral(w, rt.help(l, cmd.Command))
+		return
+	}
+	writeEphemeral(w, rt.unknownSubcommand(l, cmd.Command, name))
+}
+
+// subcommand returns the subcommand of the text, lowercased, when registered
+// for the command or `help`. It is empty for the command alone, and "other"
+// for anything else, to keep the label cardinality low.
+func (rt *commandRouter) subcommand(command, text string) string {
+	routes, ok := rt.commands[command]
+	if !ok {
+		return "other"
+	}
+
+	name, _ := cutWord(text)
+	name = strings.ToLower(name)
+	if name == "help" {
+		return name
+	}
+	for _, route := range routes {
+		if route.name == name {
+			return name
+		}
+	}
+	return "other"
+}
+
+// label names the slash command in the metrics, logs and traces, e.g.
+// "/oncall config", "/oncall" for the command alone, or "other".
+func (rt *commandRouter) label(command, text string) string {
+	switch sub := rt.subcommand(command, text); sub {
+	case "other":
+		return sub
+	case "":
+		return command
+	default:
+		return command + " " + sub
+	}
+}
+
+// help lists the subcommands of a command with their usage.
+func (rt *commandRouter) help(l slackmsg.Localizer, command string) string {
+	lines := []string{l.Text(slackmsg.MsgHelpTitle, command)}
+	for _, route := range rt.commands[command] {
+		usage := strings.Join(strings.Fields(command+" "+route.name+" "+route.usage), " ")
+		lines = append(lines, "• `"+usage+"` "+l.Text(route.description))
+	}
+	lines = append(lines, "• `"+command+" help` "+l.Text(slackmsg.MsgHelpHelp))
+	return strings.Join(lines, "\n")
+}
+
+// unknownSubcommand answers a subcommand that is not registered, suggesting
+// the closest ones.
+func (rt *commandRouter) unknownSubcommand(l slackmsg.Localizer, command, name string) string {
+	var suggestions []string
+	for _, route := range rt.commands[command] {
+		if route.name == "" {
+			continue
+		}
+		if strings.HasPrefix(route.name, name) || levenshtein(name, route.name) <= maxSuggestionDistance {
+			suggestions = append(suggestions, "`"+command+" "+route.name+"`")
+		}
+	}
+
+	parts := []string{l.Text(slackmsg.MsgUnknownSubcommand, name)}
+	if len(suggestions) > 0 {
+		parts = append(parts, l.Text(slackmsg.MsgDidYouMean, strings.Join(suggestions, " "+l.Text(slackmsg.MsgOr)+" ")))
+	}
+	return strings.Join(append(parts, l.Text(slackmsg.MsgSeeHelp, command)), " ")
+}
+
+// levenshtein returns the number of single character edits turning a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/slack-go/slack"
+)
+
+func givenRouter() *commandRouter {
+	return givenLocalizedRouter(nil)
+}
+
+func givenLocalizedRouter(localizer localizerFunc) *commandRouter {
+	rt := newCommandRouter(localizer)
+	for _, r := range []struct {
+		name        string
+		description slackmsg.MessageKey
+	}{{"", slackmsg.MsgHelpOncall}, {"all", slackmsg.MsgHelpAll}, {"config", slackmsg.MsgHelpConfig}} {
+		rt.Handle("/oncall", r.name, "", r.description, func(w http.ResponseWriter, _ *http.Request, _ slack.SlashCommand) {
+			writeEphemeral(w, "route:"+r.name)
+		})
+	}
+	rt.Handle("/pager", "", "<user>", slackmsg.MsgHelpPublic, func(w http.ResponseWriter, r *http.Request, _ slack.SlashCommand) {
+		writeEphemeral(w, "route:pager:"+localizerFromContext(r.Context()).Language())
+	})
+	return rt
+}
+
+func dispatch(t *testing.T, rt *commandRouter, command, text string) string {
+	t.Helper()
+
+	body := url.Values{"command": {command}, "text": {text}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, req)
+
+	var msg slack.Msg
+	if err := json.Unmarshal(rr.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("expected a Slack message, got %s", rr.Body.String())
+	}
+	return msg.Text
+}
+
+func TestCommandRouter_Routes(t *testing.T) {
+	rt := givenRouter()
+
+	tests := []struct {
+		command string
+		text    string
+		want    string
+	}{
+		{"/oncall", "", "route:"},
+		{"/oncall", "  ", "route:"},
+		{"/oncall", "all", "route:all"},
+		{"/oncall", "ALL refresh", "route:all"},
+		{"/oncall", "config set schedules=Platform", "route:config"},
+		{"/pager", "", "route:pager:en"},
+		{"/unknown", "", "Unknown command `/unknown`."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+" "+tt.text, func(t *testing.T) {
+			if got := dispatch(t, rt, tt.command, tt.text); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandRouter_Help(t *testing.T) {
+	got := dispatch(t, givenRouter(), "/oncall", "help")
+
+	expected := "*/oncall* subcommands:\n" +
+		"• `/oncall` Show who is on call, in the schedules of the channel\n" +
+		"• `/oncall all` Show who is on call in every schedule\n" +
+		"• `/oncall config` Show or change the schedules and the visibility of the channel\n" +
+		"• `/oncall help` Show this message"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	if got := dispatch(t, givenRouter(), "/pager", "help"); !strings.Contains(got, "• `/pager <user>` Post the answer in the channel") {
+		t.Errorf("expected the usage of /pager, got %q", got)
+	}
+}
+
+func TestCommandRouter_Localized(t *testing.T) {
+	rt := givenLocalizedRouter(func(context.Context, string, string) slackmsg.Localizer {
+		return slackmsg.NewLocalizer("fr-FR", "Europe/Paris")
+	})
+
+	tests := []struct {
+		command string
+		text    string
+		want    string
+	}{
+		{"/pager", "", "route:pager:fr"},
+		{"/unknown", "", "Commande `/unknown` inconnue."},
+		{"/oncall", "confg", "Sous-commande `confg` inconnue. Vouliez-vous dire `/oncall config` ? Voir `/oncall help`."},
+		{"/oncall", "c", "Sous-commande `c` inconnue. Vouliez-vous dire `/oncall config` ? Voir `/oncall help`."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+" "+tt.text, func(t *testing.T) {
+			if got := dispatch(t, rt, tt.command, tt.text); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := dispatch(t, rt, "/oncall", "help"); !strings.HasPrefix(got, "Sous-commandes de */oncall* :\n• `/oncall` Afficher qui est de garde") {
+		t.Errorf("expected the help in French, got %q", got)
+	}
+}
+
+func TestCommandRouter_Suggestions(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"confg", "Unknown subcommand `confg`. Did you mean `/oncall config`? See `/oncall help`."},
+		{"al", "Unknown subcommand `al`. Did you mean `/oncall all`? See `/oncall help`."},
+		{"schedules", "Unknown subcommand `schedules`. See `/oncall help`."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := dispatch(t, givenRouter(), "/oncall", tt.text); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandRouter_Label(t *testing.T) {
+	rt := givenRouter()
+
+	tests := []struct {
+		command string
+		text    string
+		want    string
+	}{
+		{"/oncall", "", "/oncall"},
+		{"/oncall", "ALL refresh", "/oncall all"},
+		{"/oncall", "config set schedules=Platform", "/oncall config"},
+		{"/oncall", "help", "/oncall help"},
+		{"/oncall", "Platform", "other"},
+		{"/pager", "", "/pager"},
+		{"/pager", "all", "other"},
+		{"/unknown", "all", "other"},
+	}
+	for _, tt := range tests {
+		if got := rt.label(tt.command, tt.text); got != tt.want {
+			t.Errorf("label(%q, %q) = %q, expected %q", tt.command, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"config", "config", 0},
+		{"confg", "config", 1},
+		{"refersh", "refresh", 2},
+		{"", "all", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -8,71 +8,297 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 
+	"github.com/metriodev/pompiers/internal/adapters/store"
 	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/audit"
+	"github.com/metriodev/pompiers/internal/domain"
 	"github.com/metriodev/pompiers/internal/middleware"
 	"github.com/metriodev/pompiers/internal/pkg/slackmsg"
+	"github.com/metriodev/pompiers/internal/policy"
+	"github.com/metriodev/pompiers/internal/tracing"
+	"github.com/slack-go/slack"
 )
 
 const (
-	errMsg = "We are having trouble processing this request. Please try again later."
+	eventsPath       = "/slack/events"
+	interactionsPath = "/slack/interactions"
 )
 
+// ServerOption allows for functional options to configure the Server
+type ServerOption func(*Server)
+
+// WithTokenStore enables per-workspace routing of Slack credentials. Requests
+// coming from a workspace that has no installation in the store are rejected.
+func WithTokenStore(tokens store.TokenStore) ServerOption {
+	return func(s *Server) {
+		s.tokens = tokens
+	}
+}
+
+// WithOAuth enables the OAuth v2 install flow. It requires a token store to
+// persist the installations.
+func WithOAuth(config OAuthConfig) ServerOption {
+	return func(s *Server) {
+		s.oauth = &config
+	}
+}
+
+// WithSettingsStore enables the per-channel and per-workspace defaults managed
+// with `/oncall config`.
+func WithSettingsStore(settings store.SettingsStore) ServerOption {
+	return func(s *Server) {
+		s.settings = settings
+	}
+}
+
+// WithAdmins grants the given Slack user IDs every privileged subcommand,
+// whatever the authorization policy.
+func WithAdmins(userIDs []string) ServerOption {
+	return func(s *Server) {
+		s.admins = userIDs
+	}
+}
+
+// WithFileSettings sets the aliases and channel defaults read from the
+// configuration file.
+func WithFileSettings(settings FileSettings) ServerOption {
+	return func(s *Server) {
+		s.fileSettings = settings
+	}
+}
+
+// WithRefresher answers the slash commands from the snapshot kept by the
+// refresher instead of calling the Atlassian APIs on every request.
+func WithRefresher(refresher *app.Refresher) ServerOption {
+	return func(s *Server) {
+		s.refresher = refresher
+	}
+}
+
+// WithPreviousSigningSecrets keeps accepting the requests signed with the
+// given secrets, until they expire, after the signing secret is rotated.
+func WithPreviousSigningSecrets(secrets ...middleware.SigningSecret) ServerOption {
+	return func(s *Server) {
+		s.previousSigningSecrets = secrets
+	}
+}
+
+// WithAuditLog records every slash command in the audit log, and enables
+// `/oncall audit`.
+func WithAuditLog(log *audit.Log) ServerOption {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// WithSlackHttpClient sets a custom HTTP client for calls made to the Slack API
+func WithSlackHttpClient(client *http.Client) ServerOption {
+	return func(s *Server) {
+		s.slackClient = client
+	}
+}
+
 type Server struct {
-	host               string
-	port               int
-	slackSigningSecret string
-	app                *app.App
-	httpserver         *http.Server
+	host                   string
+	port                   int
+	slackSigningSecret     string
+	previousSigningSecrets []middleware.SigningSecret
+	app                    *app.App
+	refresher              *app.Refresher
+	audit                  *audit.Log
+	tokens                 store.TokenStore
+	oauth                  *OAuthConfig
+	settings               store.SettingsStore
+	admins                 []string
+	apiKeys                []APIKey
+	fileSettingsMu         sync.RWMutex
+	fileSettings           FileSettings
+	slackClient            *http.Client
+	defaultLocalizer       slackmsg.Localizer
+	locales                localeCache
+	atlassianCheck         *cachedCheck
+	commands               *commandRouter
+	httpserver             *http.Server
+	cancelRequests         context.CancelFunc
 }
 
-func NewServer(app *app.App, host string, port int, slackSigningSecret string) *Server {
+func NewServer(app *app.App, host string, port int, slackSigningSecret string, opts ...ServerOption) *Server {
 	if port == 0 {
 		port = 8080
 	}
-	return &Server{
+	s := &Server{
 		host:               host,
 		port:               port,
 		app:                app,
 		slackSigningSecret: slackSigningSecret,
+		slackClient:        &http.Client{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	s.atlassianCheck = &cachedCheck{ttl: readinessCacheTTL, check: app.CheckAtlassian}
+	s.commands = s.commandRouter()
+
+	return s
 }
 
-func (s *Server) Start() error {
+// slackHandler serves the requests sent by Slack. It expects the requests to
+// be authenticated already, either by the signature verification middleware
+// or by the Socket Mode connection. Every route only accepts the POST method
+// and the content type Slack sends to it.
+func (s *Server) slackHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+eventsPath, tracing.Handler("slack event", observeLatency("events",
+		middleware.RequireContentType(http.HandlerFunc(s.handleEvents), middleware.ContentTypeJSON))))
+	mux.Handle("POST "+interactionsPath, tracing.Handler("slack interaction", observeLatency("interactions",
+		middleware.RequireContentType(s.withInstallation(http.HandlerFunc(s.handleInteractions)), middleware.ContentTypeForm))))
+	mux.Handle("POST /", tracing.Handler("slash command", observeLatency("command",
+		middleware.RequireContentType(observeCommand(s.commands, s.withAudit(s.withInstallation(s.commands))), middleware.ContentTypeForm))))
+	return withSlackAttrs(s.commands, mux)
+}
+
+// Handler builds the HTTP handler serving every endpoint of the server.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		currentSchedule, err := s.app.GetCurrentOnCallSchedule()
-		if err != nil {
-			if appErr, ok := err.(app.AppError); ok {
-				http.Error(w, appErr.Error(), appErr.HttpCode)
-			} else {
-				slog.Error("Error fetching current on-call schedule", "error", err)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(fmt.Sprintf(`{"response_type": "ephemeral", "text": "%s"}`, errMsg)))
-				return
-			}
+	// The probes don't come from Slack either
+	mux.Handle(healthzPath, s.ProbeHandler())
+	mux.Handle(readyzPath, s.ProbeHandler())
+	if s.oauth != nil {
+		// The OAuth endpoints are hit by browsers, not by Slack, so they
+		// can't be behind the signature verification.
+		mux.Handle("/slack/install", withRequestLogging(http.HandlerFunc(s.handleInstall)))
+		mux.Handle("/slack/oauth_redirect", withRequestLogging(http.HandlerFunc(s.handleOAuthRedirect)))
+	}
+	if api := s.APIHandler(); api != nil {
+		// The REST API clients are not Slack either, they hold API keys
+		mux.Handle(apiPrefix+"/", api)
+	}
+	secrets := append([]middleware.SigningSecret{{Secret: s.slackSigningSecret}}, s.previousSigningSecrets...)
+	mux.Handle("/", withRequestLogging(middleware.VerifySlackSignatures(secrets, s.slackHandler())))
+
+	return mux
+}
+
+// commandRouter registers the subcommands of the slash commands.
+func (s *Server) commandRouter() *commandRouter {
+	rt := newCommandRouter(s.localizer)
+	rt.Handle(oncallCommand, "", "", slackmsg.MsgHelpOncall, s.handleOnCall)
+	rt.Handle(oncallCommand, "all", "[refresh]", slackmsg.MsgHelpAll, s.handleOnCall)
+	rt.Handle(oncallCommand, "refresh", "[all]", slackmsg.MsgHelpRefresh, s.handleOnCall)
+	rt.Handle(oncallCommand, "public", "[all] [refresh]", slackmsg.MsgHelpPublic, s.handleOnCall)
+	rt.Handle(oncallCommand, "private", "[all] [refresh]", slackmsg.MsgHelpPrivate, s.handleOnCall)
+	rt.Handle(oncallCommand, "config", "show|set|unset ...", slackmsg.MsgHelpConfig, s.handleConfig)
+	rt.Handle(oncallCommand, "audit", "[n] [@user] [subcommand]", slackmsg.MsgHelpAudit, s.handleAudit)
+	return rt
+}
+
+// handleOnCall serves `/oncall [all] [refresh] [public|private]`, in any
+// order.
+func (s *Server) handleOnCall(w http.ResponseWriter, r *http.Request, cmd slack.SlashCommand) {
+	all, refresh := false, false
+	public := s.defaultPublic(cmd.TeamID, cmd.ChannelID)
+	for _, arg := range strings.Fields(strings.ToLower(cmd.Text)) {
+		switch arg {
+		case "all":
+			all = true
+		case "refresh":
+			refresh = true
+		case store.VisibilityPublic:
+			public = true
+		case store.VisibilityPrivate:
+			public = false
 		}
+	}
+	if refresh && !s.authorize(w, r, cmd, policy.ActionRefresh) {
+		return
+	}
+
+	filter := app.ScheduleFilter{}
+	if !all {
+		filter = s.defaultFilter(cmd.TeamID, cmd.ChannelID)
+	}
+
+	l := localizerFromContext(r.Context())
+	currentSchedule, err := s.currentSchedule(r.Context(), filter, refresh)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching current on-call schedule", "error", err)
+		setOutcome(r.Context(), outcomeError)
+		auditError(r.Context(), err)
+		writeEphemeral(w, errorText(l, err))
+		return
+	}
+
+	auditSchedules(r.Context(), scheduleNames(currentSchedule.Schedules))
 
-		response, err := slackmsg.ToSlackMessage(currentSchedule)
-		if err != nil {
-			slog.Error("Error converting to Slack message", "error", err, "schedule", currentSchedule)
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"response_type":"ephemeral","text":"We are having trouble to process this request. Please try again later."}`))
-			return
+	value := channelSchedulesValue
+	if all {
+		value = allSchedulesValue
+	}
+	opts := []slackmsg.MessageOption{slackmsg.WithActions(value), slackmsg.WithLocalizer(l)}
+	if public {
+		opts = append(opts, slackmsg.InChannel())
+	}
+
+	// Pages with buttons fit in a single message, the buttons replace it as a
+	// whole
+	newResponse().blocks(slackmsg.ToBlocks(currentSchedule, opts...)...).public(public).write(w)
+}
+
+// errorText tells the user why the schedules can't be shown, when Compass
+// rejected the credentials or throttled the requests.
+func errorText(l slackmsg.Localizer, err error) string {
+	var appErr app.AppError
+	if errors.As(err, &appErr) {
+		switch appErr.HttpCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return l.Text(slackmsg.MsgErrorUnauthorized)
+		case http.StatusTooManyRequests:
+			return l.Text(slackmsg.MsgErrorThrottled)
 		}
+	}
+	return l.Text(slackmsg.MsgError)
+}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(response)
-	})
+func scheduleNames(schedules []domain.Schedule) []string {
+	names := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		names = append(names, schedule.Name)
+	}
+	return names
+}
 
+// currentSchedule reads the schedules from the refresher snapshot when there is
+// one, from the Atlassian APIs otherwise. A forced refresh only matters to the
+// snapshot.
+func (s *Server) currentSchedule(ctx context.Context, filter app.ScheduleFilter, refresh bool) (domain.CurrentOnCallSchedule, error) {
+	if s.refresher != nil {
+		return s.refresher.Current(ctx, filter, refresh)
+	}
+	return s.app.GetCurrentOnCallSchedule(ctx, filter)
+}
+
+func (s *Server) Start() error {
+	if s.oauth != nil && s.tokens == nil {
+		return fmt.Errorf("OAuth install flow requires a token store")
+	}
+
+	// Requests still running when the shutdown times out are cancelled, which
+	// aborts their outbound calls
+	baseCtx, cancel := context.WithCancel(context.Background())
+	s.cancelRequests = cancel
 	s.httpserver = &http.Server{
-		Handler: middleware.VerifySlackSignature(s.slackSigningSecret, mux),
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
 
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.host, s.port))
 	if err != nil {
+		cancel()
 		return fmt.Errorf("net.Listen: %v", err)
 	}
 	go func(l net.Listener) {
@@ -86,6 +312,14 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Stop shuts the server down, waiting for the requests being handled until
+// ctx is done. It does nothing when Start failed before creating the server.
 func (s *Server) Stop(ctx context.Context) error {
+	if s.cancelRequests != nil {
+		defer s.cancelRequests()
+	}
+	if s.httpserver == nil {
+		return nil
+	}
 	return s.httpserver.Shutdown(ctx)
 }
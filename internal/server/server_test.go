@@ -2,17 +2,21 @@ package server_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/metriodev/pompiers/internal/adapters/api"
 	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/logging"
 	"github.com/metriodev/pompiers/internal/pkg/utils"
 	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
 )
 
 const (
@@ -20,7 +24,8 @@ const (
 	mockUser          = "mock-user"
 	mockAPIKey        = "mock-api-key"
 	mockCloudID       = "mock-cloud-id"
-	mockRequestBody   = "mock-request-body"
+	mockSiteURL       = "https://mock-site.atlassian.net"
+	mockRequestBody   = "command=%2Foncall&text="
 )
 
 func givenCompassClient(withError bool) *api.CompassClient {
@@ -65,7 +70,16 @@ func givenJiraClient() *api.JiraClient {
 		}),
 	}
 
-	return api.NewJiraClient(mockUser, mockAPIKey, api.WithJiraHttpClient(mockJiraClient))
+	return newJiraClient(mockAPIKey, mockSiteURL, api.WithJiraHttpClient(mockJiraClient))
+}
+
+// newJiraClient returns a client of the Jira API of the site.
+func newJiraClient(apiKey, siteURL string, opts ...api.JiraClientOption) *api.JiraClient {
+	client, err := api.NewJiraClient(mockUser, apiKey, append([]api.JiraClientOption{api.WithJiraBaseUrl(siteURL)}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+	return client
 }
 
 func TestMain(m *testing.M) {
@@ -73,6 +87,28 @@ func TestMain(m *testing.M) {
 	m.Run()
 }
 
+func TestServer_StopAfterFailedStart(t *testing.T) {
+	srv := server.NewServer(
+		app.NewApp(givenCompassClient(false), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithOAuth(server.OAuthConfig{ClientID: "client-id", ClientSecret: "client-secret"}),
+	)
+	if err := srv.Start(); err == nil {
+		t.Fatal("expected the OAuth install flow without token store to fail")
+	}
+	if err := srv.Stop(t.Context()); err != nil {
+		t.Errorf("expected stopping a server that never started to succeed, got %v", err)
+	}
+
+	runner := server.NewSocketModeRunner(srv, "xapp-mock")
+	if err := runner.Start(); err == nil {
+		t.Fatal("expected the OAuth install flow to fail in Socket Mode")
+	}
+	if err := runner.Stop(t.Context()); err != nil {
+		t.Errorf("expected stopping a runner that never started to succeed, got %v", err)
+	}
+}
+
 func TestServerEndpoint(t *testing.T) {
 	app := app.NewApp(givenCompassClient(false), givenJiraClient())
 	port, err := utils.FindFreePort()
@@ -86,8 +122,9 @@ func TestServerEndpoint(t *testing.T) {
 	}
 	defer server.Stop(t.Context())
 
-	httpReq, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d", port), io.NopCloser(bytes.NewBuffer([]byte(mockRequestBody))))
+	httpReq, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d", port), io.NopCloser(bytes.NewBuffer([]byte(mockRequestBody))))
 	httpReq.Header = utils.GenerateValidSlackHeaders(mockSigningSecret, mockRequestBody)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	httpClient := &http.Client{}
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
@@ -105,15 +142,15 @@ func TestServerEndpoint(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d with body: %s", http.StatusOK, resp.StatusCode, body)
 	}
 
-	if !strings.Contains(body, `{"type":"text","text":"Test Schedule: ","style":{"bold":true}}`) {
+	if !strings.Contains(body, `{"type":"mrkdwn","text":"*Test Schedule*"}`) {
 		t.Errorf("Expected body to contain 'Test Schedule', got: %s", body)
 	}
 
-	if !strings.Contains(body, `{"type":"text","text":"Test Schedule: ","style":{"bold":true}}`) {
+	if !strings.Contains(body, `{"type":"mrkdwn","text":"*Test Schedule*"}`) {
 		t.Errorf("Expected body to contain 'Test Schedule', got: %s", body)
 	}
 
-	if !strings.Contains(body, `{"type":"text","text":"Test User","style":{}}`) {
+	if !strings.Contains(body, `{"type":"mrkdwn","text":"Test User"}`) {
 		t.Errorf("Expected body to contain 'user-1', got: %s", body)
 	}
 }
@@ -131,8 +168,9 @@ func TestErrorMessage(t *testing.T) {
 	}
 	defer server.Stop(t.Context())
 
-	httpReq, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d", port), io.NopCloser(bytes.NewBuffer([]byte(mockRequestBody))))
+	httpReq, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://127.0.0.1:%d", port), io.NopCloser(bytes.NewBuffer([]byte(mockRequestBody))))
 	httpReq.Header = utils.GenerateValidSlackHeaders(mockSigningSecret, mockRequestBody)
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	httpClient := &http.Client{}
 	resp, err := httpClient.Do(httpReq)
 	if err != nil {
@@ -150,7 +188,93 @@ func TestErrorMessage(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d with body: %s", http.StatusOK, resp.StatusCode, body)
 	}
 
-	if !strings.Contains(body, `{"response_type": "ephemeral", "text": "We are having trouble processing this request. Please try again later."}`) {
-		t.Errorf("Expected body to erro message.', got: %s", body)
+	var msg slack.Msg
+	if err := json.Unmarshal(byteBody, &msg); err != nil {
+		t.Fatalf("Expected a JSON message, got: %s", body)
+	}
+	if msg.ResponseType != slack.ResponseTypeEphemeral || msg.Text != "We are having trouble processing this request. Please try again later." {
+		t.Errorf("Expected an ephemeral error message, got: %s", body)
+	}
+}
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := logging.NewHandler("text", &buf, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv := server.NewServer(app.NewApp(givenCompassClient(true), givenJiraClient()), "", 0, mockSigningSecret)
+	sendSlashCommand(srv.Handler(), "U1", "C1", "all")
+
+	output := buf.String()
+	if !strings.Contains(output, "Error fetching current on-call schedule") {
+		t.Fatalf("expected the error to be logged, got: %s", output)
+	}
+	for _, expected := range []string{"request_id=", "team_id=T1", "user_id=U1", `command="/oncall all"`, `msg="Request handled"`, "status=200"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %s in the records, got: %s", expected, output)
+		}
+	}
+}
+
+func TestRequestLogging_Rejected(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := logging.NewHandler("text", &buf, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	srv := server.NewServer(app.NewApp(givenCompassClient(false), givenJiraClient()), "", 0, mockSigningSecret)
+	req := utils.CreateValidSlackRequest("wrong-secret", "command=%2Foncall&team_id=T1&user_id=U1")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("X-Request-Id") == "" {
+		t.Fatalf("expected the request rejected with a request ID, got %d and %v", rr.Code, rr.Header())
+	}
+	output := buf.String()
+	for _, expected := range []string{"level=WARN", `msg="Request handled"`, "request_id=" + rr.Header().Get("X-Request-Id"), "status=401"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %s in the records, got: %s", expected, output)
+		}
+	}
+	if strings.Contains(output, "team_id=") {
+		t.Errorf("expected the unauthenticated form not to be logged, got: %s", output)
+	}
+}
+
+func TestSlackRoutes_ContentType(t *testing.T) {
+	handler := server.NewServer(nil, "", 0, mockSigningSecret).Handler()
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/", "application/json"},
+		{"/slack/interactions", "application/json"},
+		{"/slack/events", "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// The bodies differ so the requests are not taken for replays
+			req := utils.CreateValidSlackRequest(mockSigningSecret, `{"path":"`+tt.path+`"}`)
+			req.URL.Path = tt.path
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("expected status %v, got %v", http.StatusUnsupportedMediaType, rr.Code)
+			}
+		})
 	}
 }
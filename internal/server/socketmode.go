@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// maxSocketModeDispatches caps the Socket Mode requests handled at once,
+// further envelopes wait for a slot
+const maxSocketModeDispatches = 16
+
+// SocketModeRunner receives the Slack requests over a Socket Mode websocket
+// instead of a public HTTP endpoint. The requests are dispatched to the same
+// handlers as the HTTP server.
+type SocketModeRunner struct {
+	server  *Server
+	client  *socketmode.Client
+	handler http.Handler
+	cancel  context.CancelFunc
+	done    chan error
+
+	// stopEvents ends the event loop, closing eventsDone, while the
+	// connection stays open for the requests being handled to be acknowledged
+	stopEvents context.CancelFunc
+	eventsDone chan struct{}
+
+	// requests is the context the requests are handled with, cancelled when
+	// they outlive Stop
+	requests       context.Context
+	cancelRequests context.CancelFunc
+
+	// slots holds a token per request being handled
+	slots    chan struct{}
+	inFlight sync.WaitGroup
+}
+
+// NewSocketModeRunner creates a runner authenticated with an app-level token
+// (xapp-...) holding the connections:write scope.
+func NewSocketModeRunner(s *Server, appToken string, opts ...slack.Option) *SocketModeRunner {
+	opts = append([]slack.Option{
+		slack.OptionAppLevelToken(appToken),
+		slack.OptionHTTPClient(s.slackClient),
+	}, opts...)
+
+	return &SocketModeRunner{
+		server:  s,
+		client:  socketmode.New(slack.New("", opts...)),
+		handler: withRequestLogging(s.slackHandler()),
+		slots:   make(chan struct{}, maxSocketModeDispatches),
+	}
+}
+
+func (r *SocketModeRunner) Start() error {
+	if r.server.oauth != nil {
+		return fmt.Errorf("OAuth install flow is not available in Socket Mode")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, stopEvents := context.WithCancel(ctx)
+	r.cancel = cancel
+	r.done = make(chan error, 1)
+	r.stopEvents = stopEvents
+	r.eventsDone = make(chan struct{})
+	r.requests, r.cancelRequests = context.WithCancel(context.Background())
+
+	go r.handleEvents(events)
+	go func() {
+		slog.Info("Connecting to Slack in Socket Mode")
+		r.done <- r.client.RunContext(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops receiving envelopes and waits for the requests being handled
+// until ctx is done, cancelling them past that, before closing the
+// connection. It does nothing when Start failed.
+func (r *SocketModeRunner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	defer r.cancel()
+	defer r.cancelRequests()
+
+	// The connection stays open until the requests being handled are
+	// acknowledged, so their work isn't cut short
+	r.stopEvents()
+	<-r.eventsDone
+	dispatched := make(chan struct{})
+	go func() {
+		r.inFlight.Wait()
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	r.cancel()
+	select {
+	case err := <-r.done:
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("socketmode.RunContext: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *SocketModeRunner) handleEvents(ctx context.Context) {
+	defer close(r.eventsDone)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-r.client.Events:
+			if !ok {
+				return
+			}
+			r.handleEvent(ctx, evt)
+		}
+	}
+}
+
+func (r *SocketModeRunner) handleEvent(ctx context.Context, evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnected:
+		slog.Info("Connected to Slack in Socket Mode")
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+		slog.Error("Socket Mode connection error", "type", evt.Type, "data", evt.Data)
+	case socketmode.EventTypeErrorBadMessage:
+		if bad, ok := evt.Data.(*socketmode.ErrorBadMessage); ok {
+			slog.Error("Error decoding Socket Mode message", "error", bad.Cause)
+		}
+	case socketmode.EventTypeSlashCommand:
+		body, err := slashCommandForm(evt.Request.Payload)
+		if err != nil {
+			slog.Error("Error decoding slash command payload", "error", err)
+			r.ack(r.requests, evt.Request, nil)
+			return
+		}
+		r.dispatch(ctx, evt.Request, "/", "application/x-www-form-urlencoded", body)
+	case socketmode.EventTypeInteractive:
+		body := url.Values{"payload": {string(evt.Request.Payload)}}.Encode()
+		r.dispatch(ctx, evt.Request, interactionsPath, "application/x-www-form-urlencoded", body)
+	case socketmode.EventTypeEventsAPI:
+		r.dispatch(ctx, evt.Request, eventsPath, "application/json", string(evt.Request.Payload))
+	}
+}
+
+// dispatch replays the Socket Mode request against the HTTP handlers in its
+// own goroutine, so a slow request doesn't hold back the envelopes received
+// after it, and acknowledges the envelope as soon as the handler response is
+// ready. It waits for a slot when maxSocketModeDispatches requests are being
+// handled, unless ctx, the event loop one, is done first. The request itself
+// is handled with the runner's requests context, which outlives the loop.
+func (r *SocketModeRunner) dispatch(ctx context.Context, req *socketmode.Request, path, contentType, body string) {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+
+	r.inFlight.Add(1)
+	go func() {
+		defer func() {
+			<-r.slots
+			r.inFlight.Done()
+		}()
+		r.serve(r.requests, req, path, contentType, body)
+	}()
+}
+
+// serve replays the Socket Mode request against the HTTP handlers and
+// acknowledges the envelope with the handler response.
+func (r *SocketModeRunner) serve(ctx context.Context, req *socketmode.Request, path, contentType, body string) {
+	httpReq := httptest.NewRequestWithContext(ctx, http.MethodPost, path, strings.NewReader(body))
+	httpReq.Header.Set("Content-Type", contentType)
+
+	rr := httptest.NewRecorder()
+	r.handler.ServeHTTP(rr, httpReq)
+
+	if rr.Code != http.StatusOK {
+		slog.WarnContext(ctx, "Socket Mode request was not handled", "type", req.Type, "status", rr.Code)
+		r.ack(ctx, req, nil)
+		return
+	}
+
+	if req.AcceptsResponsePayload && rr.Body.Len() > 0 && strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+		r.ack(ctx, req, json.RawMessage(rr.Body.Bytes()))
+		return
+	}
+	r.ack(ctx, req, nil)
+}
+
+// ack acknowledges the envelope, unless ctx is cancelled first.
+func (r *SocketModeRunner) ack(ctx context.Context, req *socketmode.Request, payload any) {
+	if err := r.client.AckCtx(ctx, req.EnvelopeID, payload); err != nil {
+		slog.WarnContext(ctx, "Error acknowledging Socket Mode request", "type", req.Type, "error", err)
+	}
+}
+
+// slashCommandForm converts the JSON slash command payload received over
+// Socket Mode into the form encoding Slack uses over HTTP.
+func slashCommandForm(payload json.RawMessage) (string, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	for key, value := range fields {
+		if str, ok := value.(string); ok {
+			form.Set(key, str)
+		}
+	}
+	return form.Encode(), nil
+}
@@ -0,0 +1,248 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/metriodev/pompiers/internal/adapters/api"
+	"github.com/metriodev/pompiers/internal/adapters/store"
+	"github.com/metriodev/pompiers/internal/app"
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"github.com/metriodev/pompiers/internal/server"
+	"github.com/slack-go/slack"
+)
+
+type socketModeAck struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// givenSocketModeServer starts a local stand-in for the Slack Socket Mode
+// endpoint. It sends the given envelopes once the client is connected and
+// forwards the acknowledgements it receives.
+func givenSocketModeServer(t *testing.T, envelopes ...string) (string, <-chan socketModeAck) {
+	t.Helper()
+
+	acks := make(chan socketModeAck, len(envelopes))
+	upgrader := websocket.Upgrader{
+		// The Socket Mode client always sends the api.slack.com origin
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xapp-mock" {
+			t.Errorf("expected app-level token, got '%s'", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ok": true, "url": "ws://%s/ws"}`, strings.TrimPrefix(srv.URL, "http://"))
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade connection: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "hello", "num_connections": 1}`))
+		for _, envelope := range envelopes {
+			conn.WriteMessage(websocket.TextMessage, []byte(envelope))
+		}
+
+		for range envelopes {
+			var ack socketModeAck
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			acks <- ack
+		}
+	})
+
+	return srv.URL + "/", acks
+}
+
+func waitForAck(t *testing.T, acks <-chan socketModeAck) socketModeAck {
+	t.Helper()
+
+	select {
+	case ack := <-acks:
+		return ack
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Socket Mode acknowledgement")
+		return socketModeAck{}
+	}
+}
+
+func TestSocketMode_SlashCommand(t *testing.T) {
+	apiURL, acks := givenSocketModeServer(t,
+		`{"type": "slash_commands", "envelope_id": "env-1", "accepts_response_payload": true,
+		  "payload": {"command": "/oncall", "text": "", "team_id": "T1", "user_id": "U1", "is_enterprise_install": "false"}}`,
+	)
+
+	srv := server.NewServer(app.NewApp(givenCompassClient(false), givenJiraClient()), "", 0, mockSigningSecret)
+	runner := server.NewSocketModeRunner(srv, "xapp-mock", slack.OptionAPIURL(apiURL))
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Failed to start runner: %v", err)
+	}
+	defer runner.Stop(t.Context())
+
+	ack := waitForAck(t, acks)
+	if ack.EnvelopeID != "env-1" {
+		t.Errorf("expected envelope ID 'env-1', got '%s'", ack.EnvelopeID)
+	}
+	if !strings.Contains(string(ack.Payload), `{"type":"mrkdwn","text":"Test User"}`) {
+		t.Errorf("expected ack payload to contain the schedule, got: %s", ack.Payload)
+	}
+}
+
+func TestSocketMode_EventsAndInteractions(t *testing.T) {
+	apiURL, acks := givenSocketModeServer(t,
+		`{"type": "interactive", "envelope_id": "env-1", "payload": {"type": "block_actions"}}`,
+		`{"type": "events_api", "envelope_id": "env-2",
+		  "payload": {"type": "event_callback", "team_id": "T1", "event": {"type": "app_uninstalled"}}}`,
+	)
+
+	tokens := store.NewMemoryTokenStore()
+	tokens.Save(store.Installation{TeamID: "T1", BotToken: "xoxb-1"})
+	srv := server.NewServer(
+		app.NewApp(givenCompassClient(false), givenJiraClient()),
+		"", 0, mockSigningSecret,
+		server.WithTokenStore(tokens),
+	)
+	runner := server.NewSocketModeRunner(srv, "xapp-mock", slack.OptionAPIURL(apiURL))
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Failed to start runner: %v", err)
+	}
+	defer runner.Stop(t.Context())
+
+	// The envelopes are handled concurrently, their acks come in any order
+	acked := map[string]bool{}
+	for range 2 {
+		ack := waitForAck(t, acks)
+		acked[ack.EnvelopeID] = true
+		if len(ack.Payload) != 0 {
+			t.Errorf("expected empty ack payload, got: %s", ack.Payload)
+		}
+	}
+	if !acked["env-1"] || !acked["env-2"] {
+		t.Errorf("expected envelopes 'env-1' and 'env-2' acknowledged, got %v", acked)
+	}
+
+	if _, err := tokens.Get("T1"); err == nil {
+		t.Error("expected installation to be removed by the app_uninstalled event")
+	}
+}
+
+func TestSocketMode_SlowRequestDoesNotBlock(t *testing.T) {
+	apiURL, acks := givenSocketModeServer(t,
+		`{"type": "slash_commands", "envelope_id": "env-slow", "accepts_response_payload": true,
+		  "payload": {"command": "/oncall", "text": "", "team_id": "T1", "user_id": "U1", "is_enterprise_install": "false"}}`,
+		`{"type": "interactive", "envelope_id": "env-fast", "payload": {"type": "block_actions"}}`,
+	)
+
+	// Compass answers the slash command once the interaction is acknowledged
+	release := make(chan struct{})
+	compass := api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithHttpClient(&http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			<-release
+			body := `{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`
+			if !strings.Contains(req.URL.Path, "/on-calls") {
+				body = `{"values": [{"id": "schedule-1", "name": "Test Schedule"}]}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}))
+
+	srv := server.NewServer(app.NewApp(compass, givenJiraClient()), "", 0, mockSigningSecret)
+	runner := server.NewSocketModeRunner(srv, "xapp-mock", slack.OptionAPIURL(apiURL))
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Failed to start runner: %v", err)
+	}
+	defer runner.Stop(t.Context())
+
+	if ack := waitForAck(t, acks); ack.EnvelopeID != "env-fast" {
+		t.Errorf("expected envelope 'env-fast' acknowledged first, got '%s'", ack.EnvelopeID)
+	}
+	close(release)
+
+	ack := waitForAck(t, acks)
+	if ack.EnvelopeID != "env-slow" {
+		t.Errorf("expected envelope 'env-slow', got '%s'", ack.EnvelopeID)
+	}
+	if !strings.Contains(string(ack.Payload), `{"type":"mrkdwn","text":"Test User"}`) {
+		t.Errorf("expected ack payload to contain the schedule, got: %s", ack.Payload)
+	}
+}
+
+func TestSocketMode_StopWaitsForRequests(t *testing.T) {
+	apiURL, _ := givenSocketModeServer(t,
+		`{"type": "slash_commands", "envelope_id": "env-1", "accepts_response_payload": true,
+		  "payload": {"command": "/oncall", "text": "", "team_id": "T1", "user_id": "U1", "is_enterprise_install": "false"}}`,
+	)
+
+	// Compass answers once Stop is waiting, reporting whether the request
+	// was cancelled in the meantime
+	called := make(chan struct{}, 1)
+	release := make(chan struct{})
+	cancelled := make(chan error, 10)
+	compass := api.NewCompassClient(mockUser, mockAPIKey, mockCloudID, api.WithHttpClient(&http.Client{
+		Transport: utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			select {
+			case called <- struct{}{}:
+			default:
+			}
+			<-release
+			cancelled <- req.Context().Err()
+			body := `{"onCallParticipants": [{"id": "user-1", "type": "user"}]}`
+			if !strings.Contains(req.URL.Path, "/on-calls") {
+				body = `{"values": [{"id": "schedule-1", "name": "Test Schedule"}]}`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}))
+
+	srv := server.NewServer(app.NewApp(compass, givenJiraClient()), "", 0, mockSigningSecret)
+	runner := server.NewSocketModeRunner(srv, "xapp-mock", slack.OptionAPIURL(apiURL))
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Failed to start runner: %v", err)
+	}
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the slash command to reach Compass")
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- runner.Stop(ctx)
+	}()
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("expected Stop to wait for the slash command, returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	if err := <-stopped; err != nil {
+		t.Errorf("expected Stop to succeed, got %v", err)
+	}
+	if err := <-cancelled; err != nil {
+		t.Errorf("expected the slash command not to be cancelled during Stop, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/metriodev/pompiers/internal/pkg/utils"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func givenTracerProvider() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+func TestLogHandler(t *testing.T) {
+	provider, _ := givenTracerProvider()
+	ctx, span := provider.Tracer("test").Start(t.Context(), "test")
+	defer span.End()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+
+	logger.InfoContext(ctx, "with span")
+	if !strings.Contains(buf.String(), "trace_id="+span.SpanContext().TraceID().String()) {
+		t.Errorf("expected the trace ID in the record, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.Info("without span")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("expected no trace ID without a span, got: %s", buf.String())
+	}
+}
+
+func TestTransport(t *testing.T) {
+	provider, recorder := givenTracerProvider()
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "parent")
+
+	var traceparent string
+	client := &http.Client{
+		Transport: otelTransportWithProvider(provider, utils.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			traceparent = req.Header.Get("traceparent")
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+		})),
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.atlassian.com/compass/cloud/abc/ops/v1/schedules/s-1/on-calls", nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the call and parent spans, got %d", len(spans))
+	}
+	call := spans[0]
+	if call.Name() != "GET /compass/cloud/{cloudId}/ops/v1/schedules/{scheduleId}/on-calls" {
+		t.Errorf("unexpected span name %q", call.Name())
+	}
+	if call.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the call span to be a child of the parent span")
+	}
+	if traceparent == "" {
+		t.Error("expected the trace context to be propagated")
+	}
+}
+
+// otelTransportWithProvider is Transport using the given provider instead of
+// the global one.
+func otelTransportWithProvider(provider *sdktrace.TracerProvider, next http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(next, append(
+		transportOptions(),
+		otelhttp.WithTracerProvider(provider),
+		otelhttp.WithPropagators(propagation.TraceContext{}),
+	)...)
+}